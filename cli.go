@@ -0,0 +1,121 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/senexdrake/furaffinity-notifier/internal/conf"
+	"github.com/senexdrake/furaffinity-notifier/internal/db"
+	"github.com/senexdrake/furaffinity-notifier/internal/util"
+)
+
+// runCommand runs the subcommand given on the command line, if any. It returns false if the bot should be started
+// instead.
+func runCommand(args []string) (bool, error) {
+	if len(args) == 0 {
+		return false, nil
+	}
+	switch args[0] {
+	case "migrate":
+		return true, migrateCommand(args[1:], os.Stdout)
+	case "backup":
+		return true, backupCommand(args[1:], os.Stdout)
+	}
+	return true, fmt.Errorf("unknown command '%s'", args[0])
+}
+
+// migrateCommand either shows the state of the database schema ("migrate status") or applies pending migrations
+// ("migrate up"). "migrate up" runs the same database setup as starting the bot, so tables of new models are created
+// and cookies are encrypted with the configured key as well. With -dry-run, it only lists the migrations that would be
+// applied.
+func migrateCommand(args []string, out io.Writer) error {
+	command := "status"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "only list the migrations that would be applied")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	switch command {
+	case "status":
+		return migrationStatus(out)
+	case "up":
+		applied, err := db.Migrate(*dryRun)
+		for _, migration := range applied {
+			prefix := "Applied"
+			if *dryRun {
+				prefix = "Would apply"
+			}
+			fmt.Fprintf(out, "%s migration %d (%s)\n", prefix, migration.Version, migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if !*dryRun {
+			// The migrations have been applied already, this creates missing tables and encrypts cookies
+			db.SetCookieKeyring(conf.LoadCookieKeyring())
+			db.CreateDatabase()
+		}
+		if len(applied) == 0 {
+			fmt.Fprintln(out, "Database is up to date")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown migrate command '%s', expected 'status' or 'up'", command)
+}
+
+func migrationStatus(out io.Writer) error {
+	fmt.Fprintf(out, "Schema version: %d (latest: %d)\n\n", db.SchemaVersion(), db.LatestSchemaVersion())
+
+	writer := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "VERSION\tNAME\tSTATUS")
+	for _, status := range db.MigrationStatuses() {
+		state := "pending"
+		if status.AppliedAt != nil {
+			state = "applied " + status.AppliedAt.Local().Format(time.DateTime)
+		} else if status.Applied {
+			state = "applied"
+		}
+		fmt.Fprintf(writer, "%d\t%s\t%s\n", status.Version, status.Name, state)
+	}
+	return writer.Flush()
+}
+
+// backupCommand creates a snapshot of the database while the bot may keep running. Without -output, the backup is
+// created in the backup directory, where old backups are rotated. The flags default to the configured backup settings.
+func backupCommand(args []string, out io.Writer) error {
+	conf.LoadBackupSettings()
+	defaults := backupOptions()
+	options := db.BackupOptions{}
+	flags := flag.NewFlagSet("backup", flag.ContinueOnError)
+	output := flags.String("output", "", "file to write the backup to instead of the backup directory")
+	flags.StringVar(&options.Dir, "dir", defaults.Dir, "directory to create the backup in")
+	flags.IntVar(&options.Keep, "keep", defaults.Keep, "number of backups to keep in the backup directory, 0 keeps all")
+	flags.BoolVar(&options.Compress, "compress", defaults.Compress, "compress the backup with gzip")
+	flags.BoolVar(&options.RedactCookies, "redact-cookies", defaults.RedactCookies,
+		"remove the FA cookies of all users from the backup")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var info *db.BackupInfo
+	var err error
+	if *output != "" {
+		info, err = db.Backup(*output, options)
+	} else {
+		info, err = db.CreateBackup(options, time.Now())
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Created backup %s (%s)\n", info.Path, util.FormatBytes(info.Size))
+	return nil
+}
@@ -0,0 +1,128 @@
+// Package chart renders simple bar charts as PNG images, without relying on external services.
+package chart
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"strconv"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// BarChart is a single chart with one bar per value. Labels are written below the bars and are thinned out if there
+// is not enough space for all of them.
+type BarChart struct {
+	Title  string
+	Labels []string
+	Values []int
+}
+
+const (
+	width       = 720
+	panelHeight = 240
+	// margin surrounds the plot area of each panel, leaving space for the title, the axis and the labels
+	marginLeft   = 40
+	marginRight  = 12
+	marginTop    = 32
+	marginBottom = 24
+)
+
+var (
+	colorBackground = color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	colorBar        = color.RGBA{R: 0x3d, G: 0x7e, B: 0xc9, A: 0xff}
+	colorAxis       = color.RGBA{R: 0x99, G: 0x99, B: 0x99, A: 0xff}
+	colorGrid       = color.RGBA{R: 0xe6, G: 0xe6, B: 0xe6, A: 0xff}
+	colorText       = color.RGBA{R: 0x22, G: 0x22, B: 0x22, A: 0xff}
+)
+
+var face = basicfont.Face7x13
+
+// Render draws the charts below each other and encodes them as PNG.
+func Render(charts ...BarChart) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, width, panelHeight*max(len(charts), 1)))
+	draw.Draw(img, img.Bounds(), image.NewUniform(colorBackground), image.Point{}, draw.Src)
+	for i, chart := range charts {
+		chart.draw(img, image.Rect(0, i*panelHeight, width, (i+1)*panelHeight))
+	}
+
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (bc *BarChart) draw(img *image.RGBA, bounds image.Rectangle) {
+	drawText(img, bc.Title, bounds.Min.X+marginLeft, bounds.Min.Y+marginTop/2+4)
+	plot := image.Rect(bounds.Min.X+marginLeft, bounds.Min.Y+marginTop, bounds.Max.X-marginRight, bounds.Max.Y-marginBottom)
+
+	maxValue := 0
+	for _, value := range bc.Values {
+		maxValue = max(maxValue, value)
+	}
+	// Horizontal grid lines at the half and the maximum of the scale
+	scale := max(maxValue, 1)
+	for _, gridValue := range []int{scale / 2, scale} {
+		if gridValue == 0 {
+			continue
+		}
+		y := plot.Max.Y - gridValue*plot.Dy()/scale
+		fillRect(img, image.Rect(plot.Min.X, y, plot.Max.X, y+1), colorGrid)
+		label := strconv.Itoa(gridValue)
+		drawText(img, label, plot.Min.X-6-textWidth(label), y+4)
+	}
+	fillRect(img, image.Rect(plot.Min.X, plot.Max.Y, plot.Max.X, plot.Max.Y+1), colorAxis)
+
+	if len(bc.Values) == 0 {
+		return
+	}
+	slot := plot.Dx() / len(bc.Values)
+	barWidth := max(slot*3/4, 1)
+	labelStep := labelStep(bc.Labels, slot)
+	for i, value := range bc.Values {
+		x := plot.Min.X + i*slot + (slot-barWidth)/2
+		height := value * plot.Dy() / scale
+		fillRect(img, image.Rect(x, plot.Max.Y-height, x+barWidth, plot.Max.Y), colorBar)
+
+		if i < len(bc.Labels) && i%labelStep == 0 {
+			label := bc.Labels[i]
+			drawText(img, label, x+(barWidth-textWidth(label))/2, plot.Max.Y+16)
+		}
+	}
+}
+
+// labelStep returns how many bars are skipped between labels, so labels don't overlap.
+func labelStep(labels []string, slot int) int {
+	widest := 0
+	for _, label := range labels {
+		widest = max(widest, textWidth(label))
+	}
+	if slot <= 0 {
+		return max(len(labels), 1)
+	}
+	// Keep a gap of at least one character between labels
+	return max((widest+face.Advance+slot-1)/slot, 1)
+}
+
+func fillRect(img *image.RGBA, rect image.Rectangle, c color.Color) {
+	draw.Draw(img, rect, image.NewUniform(c), image.Point{}, draw.Src)
+}
+
+func textWidth(text string) int {
+	return font.MeasureString(face, text).Ceil()
+}
+
+func drawText(img *image.RGBA, text string, x int, y int) {
+	drawer := font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(colorText),
+		Face: face,
+		Dot:  fixed.P(x, y),
+	}
+	drawer.DrawString(text)
+}
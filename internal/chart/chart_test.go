@@ -0,0 +1,49 @@
+package chart
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRender(t *testing.T) {
+	data, err := Render(
+		BarChart{Title: "Days", Labels: []string{"1", "2", "3"}, Values: []int{0, 4, 2}},
+		BarChart{Title: "Empty"},
+	)
+	require.NoError(t, err)
+
+	img, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, width, img.Bounds().Dx())
+	assert.Equal(t, 2*panelHeight, img.Bounds().Dy())
+
+	// The highest bar reaches the top of the plot area
+	plotWidth := width - marginLeft - marginRight
+	x := marginLeft + plotWidth/3 + plotWidth/6
+	r, g, b, _ := img.At(x, marginTop+1).RGBA()
+	br, bg, bb, _ := colorBar.RGBA()
+	assert.Equal(t, []uint32{br, bg, bb}, []uint32{r, g, b})
+}
+
+func TestLabelStep(t *testing.T) {
+	tests := []struct {
+		name     string
+		labels   []string
+		slot     int
+		expected int
+	}{
+		{name: "enough space", labels: []string{"1", "2"}, slot: 30, expected: 1},
+		{name: "wide labels", labels: []string{"10", "11"}, slot: 10, expected: 3},
+		{name: "no space", labels: []string{"1", "2"}, slot: 0, expected: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, labelStep(tt.labels, tt.slot))
+		})
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/fanonwue/goutils/dsext"
 	"github.com/fanonwue/goutils/logging"
 	"github.com/senexdrake/furaffinity-notifier/internal/fa/entries"
+	"github.com/senexdrake/furaffinity-notifier/internal/secrets"
 	"github.com/senexdrake/furaffinity-notifier/internal/util"
 )
 
@@ -35,12 +36,30 @@ const DefaultMessageContentLength uint = MaxMessageContentLength
 
 var iterateSubmissionsBackwards = true
 var enableLoginCheck = true
+var enableCounterProbe = true
 var enableKitoraRequestFormCheck = false
 var enableExternalLinkRewrite = true
+var enableDriftDetection = true
+var driftDumpPath = "./data/drift"
+var notifyOutages = true
+var sendStoryFiles = false
+var knownEntryRetention = 0
+var knownEntryKeepLatest = 0
+var backupDir = "./data/backups"
+var backupInterval = 0
+var backupKeep = 7
+var backupCompress = true
+var backupRedactCookies = false
+
+var faRequestsPerSecond = 2.0
+var faMaxInFlight = 4
+var updateWorkers = 4
+var userConcurrency = 4
 
 var MessageContentLength = DefaultMessageContentLength
 var TelegramCreatorId int64 = 0
 var BotToken = ""
+var cookieKeyring *secrets.Keyring
 
 var setupDone = false
 var setupMut = sync.Mutex{}
@@ -55,6 +74,7 @@ func Setup() {
 	MessageContentLength = readMessageContentLength()
 	TelegramCreatorId = readTelegramCreatorId()
 	BotToken = readBotToken()
+	cookieKeyring = readCookieKeyring()
 
 	if EnableUserFilters {
 		entryUserFilters = readEntryUserFilters()
@@ -65,13 +85,33 @@ func Setup() {
 	}
 
 	enableLoginCheck = envBoolLog("ENABLE_LOGIN_CHECK", enableLoginCheck)
+	enableCounterProbe = envBoolLog("ENABLE_COUNTER_PROBE", enableCounterProbe)
 	enableExternalLinkRewrite = envBoolLog("ENABLE_EXTERNAL_LINK_REWRITE", enableExternalLinkRewrite)
+	faRequestsPerSecond = envFloatLog("FA_REQUESTS_PER_SECOND", faRequestsPerSecond)
+	faMaxInFlight = int(envIntLog("FA_MAX_IN_FLIGHT", int64(faMaxInFlight)))
+	updateWorkers = max(int(envIntLog("UPDATE_WORKERS", int64(updateWorkers))), 1)
+	userConcurrency = max(int(envIntLog("USER_CONCURRENCY", int64(userConcurrency))), 1)
+	enableDriftDetection = envBoolLog("ENABLE_DRIFT_DETECTION", enableDriftDetection)
+	driftDumpPath = envStringLog("DRIFT_DUMP_PATH", driftDumpPath)
+	notifyOutages = envBoolLog("NOTIFY_OUTAGES", notifyOutages)
+	sendStoryFiles = envBoolLog("SEND_STORY_FILES", sendStoryFiles)
+	knownEntryRetention = max(int(envIntLog("KNOWN_ENTRY_RETENTION_DAYS", int64(knownEntryRetention))), 0)
+	knownEntryKeepLatest = max(int(envIntLog("KNOWN_ENTRY_KEEP_LATEST", int64(knownEntryKeepLatest))), 0)
+	readBackupSettings()
 
 	if EnableMiscJobs {
 		enableKitoraRequestFormCheck = envBoolLog("ENABLE_KITORA_FORM_CHECK", enableKitoraRequestFormCheck)
 	}
 }
 
+func readBackupSettings() {
+	backupDir = envStringLog("BACKUP_DIR", backupDir)
+	backupInterval = max(int(envIntLog("BACKUP_INTERVAL_HOURS", int64(backupInterval))), 0)
+	backupKeep = max(int(envIntLog("BACKUP_KEEP", int64(backupKeep))), 0)
+	backupCompress = envBoolLog("BACKUP_COMPRESS", backupCompress)
+	backupRedactCookies = envBoolLog("BACKUP_REDACT_COOKIES", backupRedactCookies)
+}
+
 func readMessageContentLength() uint {
 	rawLength := os.Getenv(util.PrefixEnvVar("MAX_CONTENT_LENGTH"))
 	if rawLength == "" {
@@ -109,6 +149,44 @@ func readBotToken() string {
 	return botToken
 }
 
+// readCookieKeyring reads the key used to encrypt stored cookies, either directly from the environment or from a key
+// file. Old keys are kept to decrypt cookies that have not been re-encrypted with the current key yet.
+func readCookieKeyring() *secrets.Keyring {
+	rawKey := os.Getenv(util.PrefixEnvVar("COOKIE_ENCRYPTION_KEY"))
+	if keyFile := os.Getenv(util.PrefixEnvVar("COOKIE_ENCRYPTION_KEY_FILE")); rawKey == "" && keyFile != "" {
+		content, err := os.ReadFile(keyFile)
+		if err != nil {
+			logging.Panicf("Error reading cookie encryption key file '%s': %v", keyFile, err)
+		}
+		rawKey = string(content)
+	}
+	if rawKey == "" {
+		logging.Warn("No cookie encryption key has been set, cookies will be stored in plaintext")
+		return nil
+	}
+
+	key, err := secrets.ParseKey(rawKey)
+	if err != nil {
+		logging.Panicf("Error parsing cookie encryption key: %v", err)
+	}
+
+	oldKeys := make([]*secrets.Key, 0)
+	for _, rawOldKey := range strings.Split(os.Getenv(util.PrefixEnvVar("COOKIE_ENCRYPTION_OLD_KEYS")), ",") {
+		if strings.TrimSpace(rawOldKey) == "" {
+			continue
+		}
+		oldKey, err := secrets.ParseKey(rawOldKey)
+		if err != nil {
+			logging.Errorf("Error parsing old cookie encryption key, ignoring it: %v", err)
+			continue
+		}
+		oldKeys = append(oldKeys, oldKey)
+	}
+
+	logging.Infof("Encrypting cookies with key %s (%d old keys)", key.ID(), len(oldKeys))
+	return secrets.NewKeyring(key, oldKeys...)
+}
+
 var entryUserFilters = make(map[entries.EntryType][]string)
 
 func readEntryUserFilters() map[entries.EntryType][]string {
@@ -156,14 +234,108 @@ func EntryUserFilters() map[entries.EntryType][]string {
 func EnableLoginCheck() bool {
 	return enableLoginCheck
 }
+
+// EnableCounterProbe returns whether the message counters in FA's page header should be used to skip scraping message
+// pages that have nothing new on them.
+func EnableCounterProbe() bool {
+	return enableCounterProbe
+}
+
 func EnableExternalLinkRewrite() bool {
 	return enableExternalLinkRewrite
 }
 
+// FaRequestsPerSecond returns the maximum rate of requests to FA, shared by all users.
+func FaRequestsPerSecond() float64 {
+	return faRequestsPerSecond
+}
+
+// FaMaxInFlight returns the maximum amount of concurrent requests to FA, shared by all users.
+func FaMaxInFlight() int {
+	return faMaxInFlight
+}
+
+// UpdateWorkers returns the maximum amount of users that are updated in parallel.
+func UpdateWorkers() int {
+	return updateWorkers
+}
+
+// UserConcurrency returns the maximum amount of pages fetched in parallel during the update of a single user.
+func UserConcurrency() int {
+	return userConcurrency
+}
+
+func EnableDriftDetection() bool {
+	return enableDriftDetection
+}
+
+// DriftDumpPath returns the directory pages with detected markup drift are saved to. An empty path disables dumps.
+func DriftDumpPath() string {
+	return driftDumpPath
+}
+
+// NotifyOutages returns true if the bot creator should be notified when FA becomes unavailable and when it recovers.
+func NotifyOutages() bool {
+	return notifyOutages
+}
+
+// SendStoryFiles returns true if the files of text submissions should be sent as a document after the notification.
+func SendStoryFiles() bool {
+	return sendStoryFiles
+}
+
+// KnownEntryRetention returns the number of days after which known entries are pruned, or 0 to keep them forever.
+func KnownEntryRetention() time.Duration {
+	return time.Duration(knownEntryRetention) * 24 * time.Hour
+}
+
+// KnownEntryKeepLatest returns the number of latest known entries per type that are never pruned.
+func KnownEntryKeepLatest() int {
+	return knownEntryKeepLatest
+}
+
+func BackupDir() string {
+	return backupDir
+}
+
+// BackupInterval returns the interval of scheduled backups, or 0 if backups are only created on demand.
+func BackupInterval() time.Duration {
+	return time.Duration(backupInterval) * time.Hour
+}
+
+// BackupKeep returns the number of backups to keep, or 0 to keep all of them.
+func BackupKeep() int {
+	return backupKeep
+}
+
+func BackupCompress() bool {
+	return backupCompress
+}
+
+func BackupRedactCookies() bool {
+	return backupRedactCookies
+}
+
 func EnableKitoraRequestFormCheck() bool {
 	return enableKitoraRequestFormCheck
 }
 
+// CookieKeyring returns the keyring used to encrypt stored cookies, or nil if cookies are stored in plaintext.
+func CookieKeyring() *secrets.Keyring {
+	return cookieKeyring
+}
+
+// LoadCookieKeyring reads the cookie encryption keys without the rest of the configuration, for commands that only
+// work on the database.
+func LoadCookieKeyring() *secrets.Keyring {
+	return readCookieKeyring()
+}
+
+// LoadBackupSettings reads the backup settings without the rest of the configuration, for the backup command.
+func LoadBackupSettings() {
+	readBackupSettings()
+}
+
 func IterateSubmissionsBackwards() bool {
 	return iterateSubmissionsBackwards
 }
@@ -176,3 +348,34 @@ func envBoolLog(key string, defaultValue bool) bool {
 	}
 	return ret
 }
+
+func envStringLog(key string, defaultValue string) string {
+	value, found := os.LookupEnv(util.PrefixEnvVar(key))
+	if !found {
+		return defaultValue
+	}
+	logging.Infof("Setting %s to '%s'", key, value)
+	return value
+}
+
+func envIntLog(key string, defaultValue int64) int64 {
+	ret, err := util.EnvHelper().Int(key, defaultValue)
+	if err != nil {
+		logging.Errorf("Error parsing int for key '%s': %s", key, err)
+		return defaultValue
+	}
+	return ret
+}
+
+func envFloatLog(key string, defaultValue float64) float64 {
+	raw := os.Getenv(util.PrefixEnvVar(key))
+	if raw == "" {
+		return defaultValue
+	}
+	ret, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		logging.Errorf("Error parsing float for key '%s': %s", key, err)
+		return defaultValue
+	}
+	return ret
+}
@@ -0,0 +1,88 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/senexdrake/furaffinity-notifier/internal/util"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type (
+	// ArtistTier defines how notifications about entries from an FA user are delivered.
+	ArtistTier uint8
+
+	// UserArtistTier assigns a tier other than ArtistTierNormal to an FA user.
+	UserArtistTier struct {
+		UserID   uint       `gorm:"primaryKey;autoIncrement:false;not null"`
+		Username string     `gorm:"primaryKey;not null"`
+		Tier     ArtistTier `gorm:"not null"`
+	}
+)
+
+const (
+	// ArtistTierNormal follows the usual delivery settings of the user
+	ArtistTierNormal ArtistTier = iota
+	// ArtistTierPriority is always delivered with a sound and marked as priority
+	ArtistTierPriority
+	// ArtistTierMuted is delivered without a sound
+	ArtistTierMuted
+)
+
+func ArtistTiers() []ArtistTier {
+	return []ArtistTier{ArtistTierNormal, ArtistTierPriority, ArtistTierMuted}
+}
+
+func (t ArtistTier) String() string {
+	switch t {
+	case ArtistTierNormal:
+		return "normal"
+	case ArtistTierPriority:
+		return "priority"
+	case ArtistTierMuted:
+		return "muted"
+	}
+	panic("invalid artist tier")
+}
+
+func ParseArtistTier(s string) (ArtistTier, error) {
+	for _, tier := range ArtistTiers() {
+		if strings.EqualFold(s, tier.String()) {
+			return tier, nil
+		}
+	}
+	return ArtistTierNormal, fmt.Errorf("unknown artist tier '%s'", s)
+}
+
+// ArtistTierList returns all FA users with a tier other than ArtistTierNormal, ordered by username.
+func (u *User) ArtistTierList() []UserArtistTier {
+	tiers := u.ArtistTiers
+	if tiers == nil {
+		Db().Where(&UserArtistTier{UserID: u.ID}).Order("username").Find(&tiers)
+	}
+	return tiers
+}
+
+// ArtistTierOf returns the tier of the given FA user.
+func (u *User) ArtistTierOf(username string) ArtistTier {
+	username = util.NormalizeUsername(username)
+	for _, tier := range u.ArtistTierList() {
+		if tier.Username == username {
+			return tier.Tier
+		}
+	}
+	return ArtistTierNormal
+}
+
+// SetArtistTier sets the tier of the given FA user. Setting ArtistTierNormal removes the assignment.
+func (u *User) SetArtistTier(username string, tier ArtistTier, tx *gorm.DB) error {
+	if tx == nil {
+		tx = Db()
+	}
+	userTier := UserArtistTier{UserID: u.ID, Username: util.NormalizeUsername(username), Tier: tier}
+	if tier == ArtistTierNormal {
+		return tx.Delete(&userTier).Error
+	}
+	return tx.Clauses(clause.OnConflict{DoUpdates: clause.AssignmentColumns([]string{"tier"})}).Create(&userTier).Error
+}
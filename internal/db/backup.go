@@ -0,0 +1,199 @@
+package db
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type (
+	// BackupOptions defines where backups are stored and what they contain.
+	BackupOptions struct {
+		// Dir is the directory backups are created in
+		Dir string
+		// Keep is the number of backups kept in Dir, older ones are removed. Zero keeps all backups.
+		Keep int
+		// Compress compresses backups with gzip
+		Compress bool
+		// RedactCookies removes the FA cookies of all users from backups. Users have to enter their cookies again
+		// after restoring such a backup.
+		RedactCookies bool
+	}
+
+	// BackupInfo describes a created backup.
+	BackupInfo struct {
+		Path string
+		Size int64
+	}
+)
+
+const (
+	backupPrefix        = "fa-notifier-"
+	backupTimeFormat    = "20060102-150405"
+	backupExtension     = ".db"
+	compressedExtension = ".gz"
+)
+
+var ErrBackupUnsupported = errors.New("backups are only supported for SQLite databases, use the tools of your database instead")
+
+// CreateBackup creates a backup in the backup directory and removes old backups afterwards.
+func CreateBackup(options BackupOptions, now time.Time) (*BackupInfo, error) {
+	// Backups contain the cookies of all users unless redacted, so only the bot may access them
+	if err := os.MkdirAll(options.Dir, 0700); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(options.Dir, backupPrefix+now.UTC().Format(backupTimeFormat)+backupExtension)
+	if options.Compress {
+		path += compressedExtension
+	}
+
+	info, err := Backup(path, options)
+	if err != nil {
+		return nil, err
+	}
+	if options.Keep > 0 {
+		err = RotateBackups(options.Dir, options.Keep)
+	}
+	return info, err
+}
+
+// Backup writes a consistent snapshot of the database to the given path. The database stays usable while the backup is
+// running.
+func Backup(path string, options BackupOptions) (*BackupInfo, error) {
+	if CurrentDriver() != DriverSqlite {
+		return nil, ErrBackupUnsupported
+	}
+	if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("backup %s already exists", path)
+	}
+
+	snapshotPath := path
+	if options.Compress {
+		snapshotPath = strings.TrimSuffix(path, compressedExtension) + ".tmp"
+	}
+	// Backups contain the cookies of all users unless redacted. The snapshot file is created beforehand, so it is never
+	// readable by others, not even while the snapshot is written.
+	if err := createPrivateFile(snapshotPath); err != nil {
+		return nil, err
+	}
+	if options.Compress {
+		defer os.Remove(snapshotPath)
+	}
+	if err := Db().Exec("VACUUM INTO ?", snapshotPath).Error; err != nil {
+		os.Remove(snapshotPath)
+		return nil, fmt.Errorf("error creating snapshot: %w", err)
+	}
+
+	if options.RedactCookies {
+		if err := redactSnapshot(snapshotPath); err != nil {
+			os.Remove(snapshotPath)
+			return nil, fmt.Errorf("error redacting snapshot: %w", err)
+		}
+	}
+
+	if options.Compress {
+		if err := compressFile(snapshotPath, path); err != nil {
+			os.Remove(path)
+			return nil, fmt.Errorf("error compressing snapshot: %w", err)
+		}
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	return &BackupInfo{Path: path, Size: stat.Size()}, nil
+}
+
+// createPrivateFile creates an empty file only the current user can access. It fails if the file already exists.
+func createPrivateFile(path string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// redactSnapshot removes all cookies from the snapshot. The snapshot is vacuumed afterwards, as the deleted values
+// would otherwise remain in the free pages of the file.
+func redactSnapshot(path string) error {
+	snapshot, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		return err
+	}
+	sqlDB, err := snapshot.DB()
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
+
+	if err = snapshot.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&UserCookie{}).Error; err != nil {
+		return err
+	}
+	return snapshot.Exec("VACUUM").Error
+}
+
+func compressFile(source string, target string) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	writer := gzip.NewWriter(out)
+	writer.Name = strings.TrimSuffix(filepath.Base(target), compressedExtension)
+	if _, err = io.Copy(writer, in); err != nil {
+		return err
+	}
+	if err = writer.Close(); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+// RotateBackups removes all but the latest backups in the given directory. Only files created by CreateBackup are
+// considered.
+func RotateBackups(dir string, keep int) error {
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	backups := make([]string, 0, len(dirEntries))
+	for _, entry := range dirEntries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, backupPrefix) {
+			continue
+		}
+		if strings.HasSuffix(name, backupExtension) || strings.HasSuffix(name, backupExtension+compressedExtension) {
+			backups = append(backups, name)
+		}
+	}
+	if len(backups) <= keep {
+		return nil
+	}
+
+	// The timestamp in the name sorts backups by their age
+	slices.Sort(backups)
+	errs := make([]error, 0)
+	for _, name := range backups[:len(backups)-keep] {
+		errs = append(errs, os.Remove(filepath.Join(dir, name)))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,120 @@
+package db
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestCreateBackup(t *testing.T) {
+	tests := []struct {
+		name    string
+		options BackupOptions
+		file    string
+		cookies int
+	}{
+		{"plain", BackupOptions{}, "fa-notifier-20260601-120000.db", 1},
+		{"compressed", BackupOptions{Compress: true}, "fa-notifier-20260601-120000.db.gz", 1},
+		{"redacted", BackupOptions{Compress: true, RedactCookies: true}, "fa-notifier-20260601-120000.db.gz", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useSqliteDatabase(t)
+			user := User{TelegramChatId: 1, Cookies: []UserCookie{{Name: "a", Value: "secret"}}}
+			require.NoError(t, Db().Create(&user).Error)
+
+			tt.options.Dir = filepath.Join(t.TempDir(), "backups")
+			info, err := CreateBackup(tt.options, time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC))
+			require.NoError(t, err)
+			assert.Equal(t, filepath.Join(tt.options.Dir, tt.file), info.Path)
+			assert.Positive(t, info.Size)
+
+			// Backups may contain cookies, so only the bot may read them
+			dirStat, err := os.Stat(tt.options.Dir)
+			require.NoError(t, err)
+			assert.Equal(t, os.FileMode(0700), dirStat.Mode().Perm())
+			fileStat, err := os.Stat(info.Path)
+			require.NoError(t, err)
+			assert.Equal(t, os.FileMode(0600), fileStat.Mode().Perm())
+
+			path := info.Path
+			if tt.options.Compress {
+				path = decompress(t, info.Path)
+			}
+			snapshot, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: logger.Discard})
+			require.NoError(t, err)
+			t.Cleanup(func() {
+				if sqlDB, err := snapshot.DB(); err == nil {
+					sqlDB.Close()
+				}
+			})
+
+			users := int64(0)
+			snapshot.Model(&User{}).Count(&users)
+			assert.EqualValues(t, 1, users)
+			cookies := int64(0)
+			snapshot.Model(&UserCookie{}).Count(&cookies)
+			assert.EqualValues(t, tt.cookies, cookies)
+
+			entries, err := os.ReadDir(tt.options.Dir)
+			require.NoError(t, err)
+			assert.Len(t, entries, 1, "temporary files should be removed")
+		})
+	}
+}
+
+func decompress(t *testing.T, path string) string {
+	in, err := os.Open(path)
+	require.NoError(t, err)
+	defer in.Close()
+	reader, err := gzip.NewReader(in)
+	require.NoError(t, err)
+
+	target := filepath.Join(t.TempDir(), "snapshot.db")
+	out, err := os.Create(target)
+	require.NoError(t, err)
+	defer out.Close()
+	_, err = io.Copy(out, reader)
+	require.NoError(t, err)
+	return target
+}
+
+func TestRotateBackups(t *testing.T) {
+	dir := t.TempDir()
+	files := []string{
+		"fa-notifier-20260101-000000.db.gz",
+		"fa-notifier-20260103-000000.db",
+		"fa-notifier-20260102-000000.db.gz",
+		"fa-notifier-20260104-000000.db.gz",
+		"main.db",
+		"fa-notifier-notes.txt",
+	}
+	for _, file := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, file), nil, 0600))
+	}
+
+	require.NoError(t, RotateBackups(dir, 2))
+
+	remaining := make([]string, 0)
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	for _, entry := range entries {
+		remaining = append(remaining, entry.Name())
+	}
+	assert.ElementsMatch(t, []string{
+		"fa-notifier-20260103-000000.db",
+		"fa-notifier-20260104-000000.db.gz",
+		"main.db",
+		"fa-notifier-notes.txt",
+	}, remaining)
+}
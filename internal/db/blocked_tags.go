@@ -0,0 +1,112 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fanonwue/goutils/dsext"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type (
+	// BlockedTagMode defines how notifications about submissions with blocked tags are sent.
+	BlockedTagMode uint8
+
+	// UserBlockedTag is a tag the user blocked in addition to the tag blocklist of their FA account.
+	UserBlockedTag struct {
+		UserID uint   `gorm:"primaryKey;autoIncrement:false;not null"`
+		Tag    string `gorm:"primaryKey;not null"`
+	}
+)
+
+const (
+	// BlockedTagModeWarn sends the notification with a warning and without a preview
+	BlockedTagModeWarn BlockedTagMode = iota
+	// BlockedTagModeSpoiler sends the notification with the preview image hidden behind a spoiler
+	BlockedTagModeSpoiler
+	// BlockedTagModeHideContent only sends the title of the submission
+	BlockedTagModeHideContent
+	// BlockedTagModeDrop does not notify about the submission at all
+	BlockedTagModeDrop
+)
+
+func BlockedTagModes() []BlockedTagMode {
+	return []BlockedTagMode{BlockedTagModeWarn, BlockedTagModeSpoiler, BlockedTagModeHideContent, BlockedTagModeDrop}
+}
+
+func (m BlockedTagMode) String() string {
+	switch m {
+	case BlockedTagModeWarn:
+		return "warn"
+	case BlockedTagModeSpoiler:
+		return "spoiler"
+	case BlockedTagModeHideContent:
+		return "hide"
+	case BlockedTagModeDrop:
+		return "drop"
+	}
+	panic("invalid blocked tag mode")
+}
+
+func ParseBlockedTagMode(s string) (BlockedTagMode, error) {
+	for _, mode := range BlockedTagModes() {
+		if strings.EqualFold(s, mode.String()) {
+			return mode, nil
+		}
+	}
+	return BlockedTagModeWarn, fmt.Errorf("unknown blocked tag mode '%s'", s)
+}
+
+// NormalizeTag converts a tag to the form FA uses in its tag lists.
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// ExtraBlockedTags returns the tags the user blocked in addition to the blocklist of their FA account.
+func (u *User) ExtraBlockedTags() dsext.Set[string] {
+	blockedTags := u.BlockedTags
+	if blockedTags == nil {
+		Db().Where(&UserBlockedTag{UserID: u.ID}).Find(&blockedTags)
+	}
+	tags := dsext.NewSet[string]()
+	for _, blockedTag := range blockedTags {
+		tags.Add(blockedTag.Tag)
+	}
+	return tags
+}
+
+func (u *User) AddBlockedTags(tags []string, tx *gorm.DB) error {
+	blockedTags := u.blockedTagRows(tags)
+	if len(blockedTags) == 0 {
+		return nil
+	}
+	if tx == nil {
+		tx = Db()
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&blockedTags).Error
+}
+
+func (u *User) RemoveBlockedTags(tags []string, tx *gorm.DB) error {
+	blockedTags := u.blockedTagRows(tags)
+	if len(blockedTags) == 0 {
+		return nil
+	}
+	if tx == nil {
+		tx = Db()
+	}
+	return tx.Delete(&blockedTags).Error
+}
+
+func (u *User) blockedTagRows(tags []string) []UserBlockedTag {
+	normalized := dsext.NewSet[string]()
+	for _, tag := range tags {
+		tag = NormalizeTag(tag)
+		if tag != "" {
+			normalized.Add(tag)
+		}
+	}
+	return dsext.Map(dsext.Keys(normalized), func(tag string) UserBlockedTag {
+		return UserBlockedTag{UserID: u.ID, Tag: tag}
+	})
+}
@@ -0,0 +1,98 @@
+package db
+
+import (
+	"time"
+
+	"github.com/senexdrake/furaffinity-notifier/internal/fa/entries"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type (
+	// EntryClaim marks an entry as being delivered to a user right now. Only one delivery can hold the claim for an
+	// entry, so overlapping update runs can't notify a user twice about the same entry.
+	EntryClaim struct {
+		UserID    uint              `gorm:"primaryKey;autoIncrement:false;not null"`
+		EntryType entries.EntryType `gorm:"primaryKey;autoIncrement:false;not null"`
+		ID        uint              `gorm:"primaryKey;autoIncrement:false;not null"`
+		ClaimedAt time.Time         `gorm:"not null"`
+	}
+)
+
+// ClaimTimeout is the age after which a claim is considered abandoned, e.g. because the process died while delivering
+// the entry. Abandoned claims may be taken over by the next run.
+const ClaimTimeout = 10 * time.Minute
+
+// ClaimEntry tries to claim the given entry for delivery to the user. It returns false if the entry is already being
+// delivered by another run or has already been delivered. A successful claim must either be confirmed with
+// ConfirmEntry or released with ReleaseEntry.
+func ClaimEntry(userId uint, entryType entries.EntryType, id uint) (*EntryClaim, bool, error) {
+	now := time.Now().UTC()
+	claim := EntryClaim{UserID: userId, EntryType: entryType, ID: id, ClaimedAt: now}
+	claimed := false
+
+	err := Db().Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&claim)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			// Take over the claim only if its holder has given up on it
+			result = tx.Model(&EntryClaim{}).
+				Where(&EntryClaim{UserID: userId, EntryType: entryType, ID: id}).
+				Where("claimed_at < ?", now.Add(-ClaimTimeout)).
+				Update("claimed_at", now)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return nil
+			}
+		}
+
+		// The entry might have been delivered and its claim removed since the caller checked whether it is new
+		known := int64(0)
+		err := tx.Model(&KnownEntry{}).
+			Where(&KnownEntry{EntryType: entryType, ID: id, UserID: userId}).
+			Count(&known).Error
+		if err != nil {
+			return err
+		}
+		if known > 0 {
+			return tx.Delete(&claim).Error
+		}
+		claimed = true
+		return nil
+	})
+	if err != nil || !claimed {
+		return nil, false, err
+	}
+	return &claim, true, nil
+}
+
+// ConfirmEntry records the claimed entry as delivered and removes the claim.
+func ConfirmEntry(claim *EntryClaim, sentDate time.Time) error {
+	return Db().Transaction(func(tx *gorm.DB) error {
+		err := tx.Create(&KnownEntry{
+			EntryType:  claim.EntryType,
+			ID:         claim.ID,
+			UserID:     claim.UserID,
+			NotifiedAt: new(time.Now()),
+			SentDate:   sentDate,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Delete(claim).Error
+	})
+}
+
+// ReleaseEntry removes the claim of an entry that could not be delivered, so the next run can try again.
+func ReleaseEntry(claim *EntryClaim) error {
+	return Db().Delete(claim).Error
+}
+
+func (ec *EntryClaim) BeforeSave(tx *gorm.DB) error {
+	ec.ClaimedAt = ec.ClaimedAt.UTC()
+	return nil
+}
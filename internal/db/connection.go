@@ -0,0 +1,135 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fanonwue/goutils/logging"
+	"github.com/senexdrake/furaffinity-notifier/internal/util"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type (
+	// Driver is the database backend used to store all data.
+	Driver string
+
+	connectionConfig struct {
+		driver Driver
+		// dsn is the database file for SQLite and the connection string for Postgres
+		dsn             string
+		maxOpenConns    int
+		maxIdleConns    int
+		connMaxLifetime time.Duration
+		connMaxIdleTime time.Duration
+	}
+)
+
+const (
+	DriverSqlite   Driver = "sqlite"
+	DriverPostgres Driver = "postgres"
+)
+
+const defaultDatabasePath = "./data/main.db"
+
+// readConnectionConfig reads the database configuration from the environment. SQLite is used unless another driver is
+// selected.
+func readConnectionConfig() (connectionConfig, error) {
+	config := connectionConfig{driver: DriverSqlite}
+	if rawDriver := os.Getenv(util.PrefixEnvVar("DATABASE_DRIVER")); rawDriver != "" {
+		config.driver = Driver(strings.ToLower(rawDriver))
+	}
+
+	var defaultMaxOpen, defaultMaxIdle int64
+	switch config.driver {
+	case DriverSqlite:
+		config.dsn = os.Getenv(util.PrefixEnvVar("DATABASE_PATH"))
+		if config.dsn == "" {
+			config.dsn = defaultDatabasePath
+		}
+		// Fix SQlite "database is locked"
+		defaultMaxOpen, defaultMaxIdle = 1, 1
+	case DriverPostgres, "postgresql":
+		config.driver = DriverPostgres
+		config.dsn = os.Getenv(util.PrefixEnvVar("DATABASE_DSN"))
+		if config.dsn == "" {
+			return config, fmt.Errorf("DATABASE_DSN must be set when using Postgres")
+		}
+		defaultMaxOpen, defaultMaxIdle = 10, 5
+	default:
+		return config, fmt.Errorf("unknown database driver '%s'", config.driver)
+	}
+
+	maxOpen, err := util.EnvHelper().Int("DATABASE_MAX_OPEN_CONNS", defaultMaxOpen)
+	if err != nil {
+		return config, fmt.Errorf("error parsing DATABASE_MAX_OPEN_CONNS: %w", err)
+	}
+	maxIdle, err := util.EnvHelper().Int("DATABASE_MAX_IDLE_CONNS", defaultMaxIdle)
+	if err != nil {
+		return config, fmt.Errorf("error parsing DATABASE_MAX_IDLE_CONNS: %w", err)
+	}
+	maxLifetime, err := util.EnvHelper().Int("DATABASE_CONN_MAX_LIFETIME", 0)
+	if err != nil {
+		return config, fmt.Errorf("error parsing DATABASE_CONN_MAX_LIFETIME: %w", err)
+	}
+	maxIdleTime, err := util.EnvHelper().Int("DATABASE_CONN_MAX_IDLE_TIME", 0)
+	if err != nil {
+		return config, fmt.Errorf("error parsing DATABASE_CONN_MAX_IDLE_TIME: %w", err)
+	}
+
+	config.maxOpenConns = max(int(maxOpen), 1)
+	config.maxIdleConns = min(max(int(maxIdle), 0), config.maxOpenConns)
+	config.connMaxLifetime = time.Duration(maxLifetime) * time.Second
+	config.connMaxIdleTime = time.Duration(maxIdleTime) * time.Second
+
+	if config.driver == DriverSqlite && config.maxOpenConns > 1 {
+		logging.Warnf("Using %d connections with SQLite, writes might fail with \"database is locked\"", config.maxOpenConns)
+	}
+	return config, nil
+}
+
+func (cc *connectionConfig) dialector() gorm.Dialector {
+	switch cc.driver {
+	case DriverPostgres:
+		return postgres.Open(cc.dsn)
+	default:
+		os.MkdirAll(filepath.Dir(cc.dsn), os.ModePerm)
+		return sqlite.Open(cc.dsn)
+	}
+}
+
+func (cc *connectionConfig) configurePool(sqlDB *sql.DB) {
+	sqlDB.SetMaxOpenConns(cc.maxOpenConns)
+	sqlDB.SetMaxIdleConns(cc.maxIdleConns)
+	sqlDB.SetConnMaxLifetime(cc.connMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cc.connMaxIdleTime)
+}
+
+func open(config connectionConfig) (*gorm.DB, error) {
+	openedDb, err := gorm.Open(config.dialector(), &gorm.Config{})
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := openedDb.DB()
+	if err != nil {
+		logging.Errorf("Error retrieving sql DB interface: %s", err)
+	} else {
+		config.configurePool(sqlDB)
+	}
+	logging.Debugf("Opened %s database with at most %d connections", config.driver, config.maxOpenConns)
+	return openedDb, nil
+}
+
+// CurrentDriver returns the driver of the opened database.
+func CurrentDriver() Driver {
+	if Db().Dialector.Name() == string(DriverPostgres) {
+		return DriverPostgres
+	}
+	return DriverSqlite
+}
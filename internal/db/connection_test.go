@@ -0,0 +1,157 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/senexdrake/furaffinity-notifier/internal/fa/entries"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadConnectionConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		expected connectionConfig
+		err      bool
+	}{
+		{
+			name:     "sqlite default",
+			expected: connectionConfig{driver: DriverSqlite, dsn: defaultDatabasePath, maxOpenConns: 1, maxIdleConns: 1},
+		},
+		{
+			name:     "sqlite path",
+			env:      map[string]string{"FN_DATABASE_PATH": "/tmp/test.db"},
+			expected: connectionConfig{driver: DriverSqlite, dsn: "/tmp/test.db", maxOpenConns: 1, maxIdleConns: 1},
+		},
+		{
+			name:     "postgres defaults",
+			env:      map[string]string{"FN_DATABASE_DRIVER": "Postgres", "FN_DATABASE_DSN": "host=db"},
+			expected: connectionConfig{driver: DriverPostgres, dsn: "host=db", maxOpenConns: 10, maxIdleConns: 5},
+		},
+		{
+			name: "postgres pool",
+			env: map[string]string{
+				"FN_DATABASE_DRIVER":             "postgresql",
+				"FN_DATABASE_DSN":                "postgres://db/fa",
+				"FN_DATABASE_MAX_OPEN_CONNS":     "4",
+				"FN_DATABASE_MAX_IDLE_CONNS":     "8",
+				"FN_DATABASE_CONN_MAX_LIFETIME":  "300",
+				"FN_DATABASE_CONN_MAX_IDLE_TIME": "60",
+			},
+			expected: connectionConfig{
+				driver:          DriverPostgres,
+				dsn:             "postgres://db/fa",
+				maxOpenConns:    4,
+				maxIdleConns:    4,
+				connMaxLifetime: 5 * time.Minute,
+				connMaxIdleTime: time.Minute,
+			},
+		},
+		{name: "postgres without dsn", env: map[string]string{"FN_DATABASE_DRIVER": "postgres"}, err: true},
+		{name: "unknown driver", env: map[string]string{"FN_DATABASE_DRIVER": "mysql"}, err: true},
+		{name: "invalid pool size", env: map[string]string{"FN_DATABASE_MAX_OPEN_CONNS": "many"}, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"FN_DATABASE_DRIVER", "FN_DATABASE_PATH", "FN_DATABASE_DSN"} {
+				t.Setenv(key, "")
+			}
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			config, err := readConnectionConfig()
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, config)
+		})
+	}
+}
+
+func TestDatabase_Sqlite(t *testing.T) {
+	testDatabase(t, connectionConfig{
+		driver:       DriverSqlite,
+		dsn:          filepath.Join(t.TempDir(), "test.db"),
+		maxOpenConns: 1,
+		maxIdleConns: 1,
+	})
+}
+
+// TestDatabase_Postgres runs against the Postgres database given by FN_TEST_POSTGRES_DSN. The database should be
+// empty, as the test leaves its data behind.
+func TestDatabase_Postgres(t *testing.T) {
+	dsn := os.Getenv("FN_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("FN_TEST_POSTGRES_DSN not set")
+	}
+	testDatabase(t, connectionConfig{driver: DriverPostgres, dsn: dsn, maxOpenConns: 4, maxIdleConns: 2})
+}
+
+// useDatabase replaces the database for the duration of the test.
+func useDatabase(t *testing.T, config connectionConfig) {
+	openedDb, err := open(config)
+	require.NoError(t, err)
+	previousDb := db
+	db = openedDb
+	t.Cleanup(func() { db = previousDb })
+}
+
+// useSqliteDatabase replaces the database with a new, fully migrated SQLite database for the duration of the test.
+func useSqliteDatabase(t *testing.T) {
+	useDatabase(t, connectionConfig{
+		driver:       DriverSqlite,
+		dsn:          filepath.Join(t.TempDir(), "test.db"),
+		maxOpenConns: 1,
+		maxIdleConns: 1,
+	})
+	CreateDatabase()
+}
+
+// testDatabase migrates a new database and runs a delivery through it.
+func testDatabase(t *testing.T, config connectionConfig) {
+	useDatabase(t, config)
+
+	assert.Equal(t, config.driver, CurrentDriver())
+	assert.Len(t, PendingMigrations(), len(migrations))
+
+	CreateDatabase()
+	assert.Equal(t, LatestSchemaVersion(), SchemaVersion())
+	for _, status := range MigrationStatuses() {
+		assert.True(t, status.Applied)
+		assert.NotNil(t, status.AppliedAt)
+	}
+	applied, err := Migrate(false)
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+
+	user := User{TelegramChatId: time.Now().UnixNano()}
+	require.NoError(t, Db().Create(&user).Error)
+	user.EnableEntryType(entries.EntryTypeSubmission, true, nil)
+	user.EnableEntryType(entries.EntryTypeJournal, true, nil)
+	require.NoError(t, user.SetEntryTypeRatings(entries.EntryTypeSubmission, 0b001, nil))
+	entryTypes := make([]UserEntryType, 0)
+	require.NoError(t, Db().Where(&UserEntryType{UserID: user.ID}).Order("entry_type").Find(&entryTypes).Error)
+	require.Len(t, entryTypes, 2)
+	assert.Equal(t, RatingMask(0b001), entryTypes[0].Ratings)
+	assert.Equal(t, RatingMaskAll, entryTypes[1].Ratings)
+
+	claim, claimed, err := ClaimEntry(user.ID, entries.EntryTypeSubmission, 42)
+	require.NoError(t, err)
+	require.True(t, claimed)
+	_, claimed, err = ClaimEntry(user.ID, entries.EntryTypeSubmission, 42)
+	require.NoError(t, err)
+	assert.False(t, claimed, "entry should only be claimed once")
+
+	require.NoError(t, ConfirmEntry(claim, time.Now()))
+	_, claimed, err = ClaimEntry(user.ID, entries.EntryTypeSubmission, 42)
+	require.NoError(t, err)
+	assert.False(t, claimed, "delivered entry should not be claimed again")
+}
@@ -0,0 +1,121 @@
+package db
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/fanonwue/goutils/logging"
+	"github.com/senexdrake/furaffinity-notifier/internal/secrets"
+	"gorm.io/gorm"
+)
+
+// cookieKeyring encrypts the values of stored cookies. Cookies are stored in plaintext if it is nil.
+var cookieKeyring *secrets.Keyring
+
+func SetCookieKeyring(keyring *secrets.Keyring) {
+	cookieKeyring = keyring
+}
+
+// associatedData binds the encrypted value to the user and the name of the cookie, so it can't be copied to another
+// cookie or user.
+func (c *UserCookie) associatedData() []byte {
+	return fmt.Appendf(nil, "user_cookie:%d:%s", c.UserID, c.Name)
+}
+
+// Unreadable returns true if the cookie is encrypted, but could not be decrypted. Its value can't be used then.
+func (c *UserCookie) Unreadable() bool {
+	return c.unreadable
+}
+
+func (c *UserCookie) BeforeSave(*gorm.DB) error {
+	if cookieKeyring == nil || secrets.IsEncrypted(c.Value) {
+		return nil
+	}
+	encrypted, err := cookieKeyring.Encrypt(c.Value, c.associatedData())
+	if err != nil {
+		return fmt.Errorf("error encrypting cookie %s of user %d: %w", c.Name, c.UserID, err)
+	}
+	c.Value = encrypted
+	return nil
+}
+
+// AfterSave restores the plaintext value, so the saved cookie can still be used by the caller.
+func (c *UserCookie) AfterSave(tx *gorm.DB) error {
+	return c.AfterFind(tx)
+}
+
+// AfterFind decrypts the value. Cookies that can't be decrypted are marked as unreadable and keep their encrypted value
+// instead of failing the query, as that would affect all users loaded along with them.
+func (c *UserCookie) AfterFind(*gorm.DB) error {
+	c.unreadable = false
+	if !secrets.IsEncrypted(c.Value) {
+		return nil
+	}
+	if cookieKeyring == nil {
+		logging.Errorf("Cookie %s of user %d is encrypted, but no encryption key has been set", c.Name, c.UserID)
+		c.unreadable = true
+		return nil
+	}
+	decrypted, err := cookieKeyring.Decrypt(c.Value, c.associatedData())
+	if err != nil {
+		logging.Errorf("Error decrypting cookie %s of user %d: %v", c.Name, c.UserID, err)
+		c.unreadable = true
+		return nil
+	}
+	c.Value = decrypted
+	return nil
+}
+
+// HasUnreadableCookies returns true if any of the user's cookies could not be decrypted.
+func (u *User) HasUnreadableCookies() bool {
+	cookies := u.Cookies
+	if cookies == nil {
+		Db().Where(&UserCookie{UserID: u.ID}).Find(&cookies)
+	}
+	return slices.ContainsFunc(cookies, func(c UserCookie) bool { return c.Unreadable() })
+}
+
+// migrateCookieEncryption encrypts all cookies still stored in plaintext and re-encrypts cookies encrypted with an old
+// key using the current one. Unlike the schema migrations, this runs on every start, as it depends on the configured
+// keys.
+func migrateCookieEncryption() {
+	raw := Db().Session(&gorm.Session{SkipHooks: true})
+	cookies := make([]UserCookie, 0)
+	if err := raw.Find(&cookies).Error; err != nil {
+		logging.Errorf("Error loading cookies for encryption: %v", err)
+		return
+	}
+
+	if cookieKeyring == nil {
+		for _, cookie := range cookies {
+			if secrets.IsEncrypted(cookie.Value) {
+				logging.Errorf("Found encrypted cookies, but no encryption key has been set. These cookies can't be used.")
+				return
+			}
+		}
+		return
+	}
+
+	migrated := 0
+	for _, cookie := range cookies {
+		if !cookieKeyring.NeedsRotation(cookie.Value) {
+			continue
+		}
+		rotated, err := cookieKeyring.Rotate(cookie.Value, cookie.associatedData())
+		if err != nil {
+			logging.Errorf("Error re-encrypting cookie %s of user %d: %v", cookie.Name, cookie.UserID, err)
+			continue
+		}
+		err = raw.Model(&UserCookie{}).
+			Where(&UserCookie{UserID: cookie.UserID, Name: cookie.Name}).
+			Update("value", rotated).Error
+		if err != nil {
+			logging.Errorf("Error saving re-encrypted cookie %s of user %d: %v", cookie.Name, cookie.UserID, err)
+			continue
+		}
+		migrated++
+	}
+	if migrated > 0 {
+		logging.Infof("Encrypted %d cookies with the current key", migrated)
+	}
+}
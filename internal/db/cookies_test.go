@@ -0,0 +1,64 @@
+package db
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/senexdrake/furaffinity-notifier/internal/secrets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func useCookieKey(t *testing.T, fill byte) {
+	key, err := secrets.NewKey(bytes.Repeat([]byte{fill}, secrets.KeySize))
+	require.NoError(t, err)
+	SetCookieKeyring(secrets.NewKeyring(key))
+	t.Cleanup(func() { SetCookieKeyring(nil) })
+}
+
+func TestUserCookie_Encryption(t *testing.T) {
+	useSqliteDatabase(t)
+	useCookieKey(t, 1)
+	user := User{TelegramChatId: 1, Cookies: []UserCookie{{Name: "a", Value: "secret"}}}
+	require.NoError(t, Db().Create(&user).Error)
+	assert.Equal(t, "secret", user.Cookies[0].Value, "the saved cookie should still be usable")
+
+	stored := UserCookie{}
+	require.NoError(t, Db().Session(&gorm.Session{SkipHooks: true}).First(&stored).Error)
+	assert.True(t, secrets.IsEncrypted(stored.Value))
+
+	loaded := User{}
+	require.NoError(t, Db().Preload("Cookies").First(&loaded, user.ID).Error)
+	assert.Equal(t, "secret", loaded.Cookies[0].Value)
+	assert.False(t, loaded.HasUnreadableCookies())
+}
+
+func TestUserCookie_Unreadable(t *testing.T) {
+	useSqliteDatabase(t)
+	useCookieKey(t, 1)
+	user := User{TelegramChatId: 1, Cookies: []UserCookie{{Name: "a", Value: "secret"}}}
+	other := User{TelegramChatId: 2}
+	require.NoError(t, Db().Create(&user).Error)
+	require.NoError(t, Db().Create(&other).Error)
+
+	// Values copied to another user can't be decrypted
+	stored := UserCookie{}
+	raw := Db().Session(&gorm.Session{SkipHooks: true})
+	require.NoError(t, raw.First(&stored).Error)
+	require.NoError(t, raw.Create(&UserCookie{UserID: other.ID, Name: "a", Value: stored.Value}).Error)
+
+	users := make([]User, 0)
+	require.NoError(t, Db().Preload("Cookies").Order("id").Find(&users).Error)
+	require.Len(t, users, 2)
+	assert.False(t, users[0].HasUnreadableCookies())
+	assert.True(t, users[1].HasUnreadableCookies())
+
+	// A different key doesn't fail the query either
+	useCookieKey(t, 2)
+	users = make([]User, 0)
+	require.NoError(t, Db().Preload("Cookies").Find(&users).Error)
+	require.Len(t, users, 2)
+	assert.True(t, users[0].HasUnreadableCookies())
+	assert.True(t, users[1].HasUnreadableCookies())
+}
@@ -3,15 +3,12 @@ package db
 import (
 	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
 	"time"
 
 	"github.com/fanonwue/goutils/dsext"
 	"github.com/fanonwue/goutils/logging"
 	"github.com/senexdrake/furaffinity-notifier/internal/fa/entries"
 	"github.com/senexdrake/furaffinity-notifier/internal/util"
-	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
@@ -28,24 +25,38 @@ type (
 		EntryTypes               []UserEntryType `gorm:"constraint:OnDelete:CASCADE;"`
 		Timezone                 string          `gorm:"default:'UTC';not null"`
 		InvalidCredentialsSentAt *time.Time
+		UpdateIntervalSeconds    uint                 `gorm:"default:0;not null"`
+		BlockedTagMode           BlockedTagMode       `gorm:"default:0;not null"`
+		BlockedTags              []UserBlockedTag     `gorm:"constraint:OnDelete:CASCADE;"`
+		Filters                  []UserFilter         `gorm:"constraint:OnDelete:CASCADE;"`
+		Rules                    []UserRule           `gorm:"constraint:OnDelete:CASCADE;"`
+		ArtistTiers              []UserArtistTier     `gorm:"constraint:OnDelete:CASCADE;"`
+		Notifications            []NotificationRecord `gorm:"constraint:OnDelete:CASCADE;"`
 	}
 
 	UserCookie struct {
 		UserID uint   `gorm:"primaryKey;autoIncrement:false;not null"`
 		Name   string `gorm:"primaryKey;not null"`
 		Value  string
+		// unreadable is set if the value could not be decrypted
+		unreadable bool
 	}
 
 	UserEntryType struct {
 		UserID    uint              `gorm:"primaryKey:type_per_user;autoIncrement:false;not null"`
 		EntryType entries.EntryType `gorm:"primaryKey:type_per_user;autoIncrement:false;not null"`
 		EnabledAt time.Time         `gorm:"default:current_timestamp;not null"`
+		Ratings   RatingMask        `gorm:"default:7;not null"`
+		// PrunedBefore is the date before which known entries of this type have been pruned
+		PrunedBefore *time.Time
 	}
 
+	// KnownEntry is an entry the user has already been notified about. Entries are known per user, as multiple users
+	// can be notified about the same entry.
 	KnownEntry struct {
+		UserID     uint              `gorm:"primaryKey;autoIncrement:false;not null"`
 		EntryType  entries.EntryType `gorm:"primaryKey;autoIncrement:false;default:0;not null"`
 		ID         uint              `gorm:"primaryKey;autoIncrement:false;not null"`
-		UserID     uint              `gorm:"index;not null"`
 		NotifiedAt *time.Time
 		SentDate   time.Time
 	}
@@ -128,6 +139,12 @@ func (u *User) SetCredentialsValidAndSave(valid bool, tx *gorm.DB) {
 	tx.Save(u)
 }
 
+// UpdateIntervalOverride returns the update interval set by the user or zero if the interval should be adapted
+// automatically.
+func (u *User) UpdateIntervalOverride() time.Duration {
+	return time.Duration(u.UpdateIntervalSeconds) * time.Second
+}
+
 func (u *User) InvalidCredentialsNotified() bool {
 	return u.InvalidCredentialsSentAt != nil
 }
@@ -138,11 +155,24 @@ func (e *KnownEntry) BeforeSave(tx *gorm.DB) error {
 	return nil
 }
 
+// SetEntryTypeRatings sets the ratings the user wants to be notified about for an enabled entry type.
+func (u *User) SetEntryTypeRatings(entryType entries.EntryType, ratings RatingMask, tx *gorm.DB) error {
+	if ratings == 0 {
+		return errors.New("at least one rating has to be enabled")
+	}
+	if tx == nil {
+		tx = Db()
+	}
+	// The model carries the primary key, as an empty one would be rejected by the BeforeSave hook
+	return tx.Model(&UserEntryType{UserID: u.ID, EntryType: entryType}).Update("ratings", ratings).Error
+}
+
 func NewUserEntryType(userId uint, entryType entries.EntryType) *UserEntryType {
 	uet := UserEntryType{
 		UserID:    userId,
 		EntryType: entryType,
 		EnabledAt: time.Now().UTC(),
+		Ratings:   RatingMaskAll,
 	}
 	return &uet
 }
@@ -154,32 +184,18 @@ func (uet *UserEntryType) BeforeSave(tx *gorm.DB) error {
 	return nil
 }
 
-const latestSchemaVersion = 6
-
 var db *gorm.DB
 
 func Db() *gorm.DB {
 	if db == nil {
-		databasePath := os.Getenv(util.PrefixEnvVar("DATABASE_PATH"))
-		if databasePath == "" {
-			databasePath = "./data/main.db"
-		}
-
-		os.MkdirAll(filepath.Dir(databasePath), os.ModePerm)
-
-		openedDb, err := gorm.Open(sqlite.Open(databasePath), &gorm.Config{})
+		config, err := readConnectionConfig()
 		if err != nil {
-			panic(fmt.Sprintf("error opening database: %s", err))
+			panic(fmt.Sprintf("error reading database configuration: %s", err))
 		}
-
-		sqlDB, err := openedDb.DB()
+		openedDb, err := open(config)
 		if err != nil {
-			logging.Errorf("Error retrieving sql DB interface: %s", err)
-		} else {
-			// Fix SQlite "database is locked"
-			sqlDB.SetMaxOpenConns(1)
+			panic(fmt.Sprintf("error opening database: %s", err))
 		}
-
 		db = openedDb
 	}
 
@@ -188,8 +204,9 @@ func Db() *gorm.DB {
 
 func CreateDatabase() {
 	migrate()
-	err := Db().AutoMigrate(&User{}, &UserCookie{}, &KnownEntry{}, &UserEntryType{})
+	err := Db().AutoMigrate(&User{}, &UserCookie{}, &KnownEntry{}, &UserEntryType{}, &EntryClaim{}, &UserBlockedTag{}, &UserFilter{}, &UserRule{}, &UserArtistTier{}, &NotificationRecord{})
 	if err != nil {
 		logging.Errorf("Error creating database: %s", err)
 	}
+	migrateCookieEncryption()
 }
@@ -0,0 +1,79 @@
+package db
+
+import (
+	"github.com/senexdrake/furaffinity-notifier/internal/fa/entries"
+	"github.com/senexdrake/furaffinity-notifier/internal/util"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type (
+	// FilterKind defines whether a UserFilter allows or denies entries from an FA user.
+	FilterKind uint8
+
+	// UserFilter allows or denies entries of a type from a single FA user. If a user has any allowing filters for an
+	// entry type, only entries from the allowed FA users are sent.
+	UserFilter struct {
+		UserID    uint              `gorm:"primaryKey;autoIncrement:false;not null"`
+		EntryType entries.EntryType `gorm:"primaryKey;autoIncrement:false;not null"`
+		Username  string            `gorm:"primaryKey;not null"`
+		Kind      FilterKind        `gorm:"default:0;not null"`
+	}
+)
+
+const (
+	FilterKindAllow FilterKind = iota
+	FilterKindDeny
+)
+
+func (k FilterKind) String() string {
+	switch k {
+	case FilterKindAllow:
+		return "allow"
+	case FilterKindDeny:
+		return "deny"
+	}
+	panic("invalid filter kind")
+}
+
+// UserFilterLists returns the usernames of the user's filters of the given kind, grouped by entry type.
+func (u *User) UserFilterLists(kind FilterKind) map[entries.EntryType][]string {
+	filters := u.Filters
+	if filters == nil {
+		Db().Where(&UserFilter{UserID: u.ID}).Order("username").Find(&filters)
+	}
+	lists := make(map[entries.EntryType][]string)
+	for _, filter := range filters {
+		if filter.Kind == kind {
+			lists[filter.EntryType] = append(lists[filter.EntryType], filter.Username)
+		}
+	}
+	return lists
+}
+
+// SetUserFilter allows or denies entries of the given type from the FA user, replacing an existing filter for them.
+func (u *User) SetUserFilter(entryType entries.EntryType, username string, kind FilterKind, tx *gorm.DB) error {
+	if tx == nil {
+		tx = Db()
+	}
+	filter := UserFilter{
+		UserID:    u.ID,
+		EntryType: entryType,
+		Username:  util.NormalizeUsername(username),
+		Kind:      kind,
+	}
+	return tx.Clauses(clause.OnConflict{DoUpdates: clause.AssignmentColumns([]string{"kind"})}).Create(&filter).Error
+}
+
+// RemoveUserFilter removes the filter of the FA user for the given entry type. It returns false if there was none.
+func (u *User) RemoveUserFilter(entryType entries.EntryType, username string, tx *gorm.DB) (bool, error) {
+	if tx == nil {
+		tx = Db()
+	}
+	result := tx.Delete(&UserFilter{
+		UserID:    u.ID,
+		EntryType: entryType,
+		Username:  util.NormalizeUsername(username),
+	})
+	return result.RowsAffected > 0, result.Error
+}
@@ -0,0 +1,83 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/senexdrake/furaffinity-notifier/internal/fa/entries"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestKnownEntries_MultipleUsers covers multiple users watching the same artist, who are all notified about the same
+// submission. Every user has to know the submission on their own, or they would be notified about it again.
+func TestKnownEntries_MultipleUsers(t *testing.T) {
+	useSqliteDatabase(t)
+
+	users := []User{{TelegramChatId: 1}, {TelegramChatId: 2}, {TelegramChatId: 3}}
+	require.NoError(t, Db().Create(&users).Error)
+
+	for _, user := range users {
+		claim, claimed, err := ClaimEntry(user.ID, entries.EntryTypeSubmission, 42)
+		require.NoError(t, err)
+		require.True(t, claimed, "user %d should be able to claim the submission", user.ID)
+		require.NoError(t, ConfirmEntry(claim, time.Now()))
+	}
+
+	for _, user := range users {
+		_, claimed, err := ClaimEntry(user.ID, entries.EntryTypeSubmission, 42)
+		require.NoError(t, err)
+		assert.False(t, claimed, "user %d should already know the submission", user.ID)
+
+		// The same ID of another entry type is a different entry
+		_, claimed, err = ClaimEntry(user.ID, entries.EntryTypeJournal, 42)
+		require.NoError(t, err)
+		assert.True(t, claimed)
+	}
+
+	count := int64(0)
+	Db().Model(&KnownEntry{}).Where(&KnownEntry{EntryType: entries.EntryTypeSubmission, ID: 42}).Count(&count)
+	assert.EqualValues(t, len(users), count)
+}
+
+func TestMigrate_RebuildKnownEntries(t *testing.T) {
+	useDatabase(t, connectionConfig{
+		driver:       DriverSqlite,
+		dsn:          filepath.Join(t.TempDir(), "test.db"),
+		maxOpenConns: 1,
+		maxIdleConns: 1,
+	})
+
+	// The schema before entries were known per user
+	for _, statement := range []string{
+		"CREATE TABLE `known_entries` (`entry_type` integer NOT NULL DEFAULT 0,`id` integer NOT NULL," +
+			"`user_id` integer NOT NULL,`notified_at` datetime,`sent_date` datetime,PRIMARY KEY (`entry_type`,`id`))",
+		"CREATE INDEX `idx_known_entries_user_id` ON `known_entries`(`user_id`)",
+		"INSERT INTO `known_entries` VALUES (1, 42, 1, '2026-01-01 00:00:00+00:00', '2026-01-01 00:00:00+00:00')",
+		"INSERT INTO `known_entries` VALUES (1, 43, 2, NULL, '2026-01-02 00:00:00+00:00')",
+	} {
+		require.NoError(t, Db().Exec(statement).Error)
+	}
+	require.NoError(t, Db().Migrator().AutoMigrate(&SchemaInfo{}))
+	require.NoError(t, Db().Create(&SchemaInfo{Version: 10}).Error)
+
+	applied, err := Migrate(false)
+	require.NoError(t, err)
+	require.Len(t, applied, 1)
+	assert.EqualValues(t, 11, applied[0].Version)
+
+	knownEntries := make([]KnownEntry, 0)
+	require.NoError(t, Db().Order("id").Find(&knownEntries).Error)
+	require.Len(t, knownEntries, 2)
+	assert.EqualValues(t, 1, knownEntries[0].UserID)
+	assert.EqualValues(t, 42, knownEntries[0].ID)
+	assert.NotNil(t, knownEntries[0].NotifiedAt)
+	assert.Nil(t, knownEntries[1].NotifiedAt)
+	assert.EqualValues(t, 2, knownEntries[1].UserID)
+
+	// The second user can now know the entry of the first one as well
+	require.NoError(t, Db().Create(&KnownEntry{UserID: 2, EntryType: 1, ID: 42, SentDate: time.Now()}).Error)
+	assert.True(t, Db().Migrator().HasTable(&KnownEntry{}))
+	assert.False(t, Db().Migrator().HasTable("known_entries_new"))
+}
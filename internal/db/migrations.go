@@ -1,54 +1,283 @@
 package db
 
 import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/fanonwue/goutils/logging"
+	"github.com/senexdrake/furaffinity-notifier/internal/fa/entries"
 	"gorm.io/gorm"
 )
 
-func migrate() {
-	migrator := Db().Migrator()
-
-	if !migrator.HasTable(&SchemaInfo{}) {
-		migrator.CreateTable(&SchemaInfo{})
-		Db().Create(&SchemaInfo{Version: 1})
-
-		if !migrator.HasTable(&User{}) {
-			// Assume this is a completely new DB
-			migrator.AutoMigrate(&User{}, &UserCookie{}, &KnownEntry{})
-			err := updateSchemaVersion(latestSchemaVersion)
-			if err != nil {
-				panic(err)
-			}
+type (
+	// Migration is a single, versioned step of the schema. Its Up function is run in a transaction together with
+	// recording the new version, so a failing migration leaves the schema untouched.
+	Migration struct {
+		Version uint
+		Name    string
+		Up      func(tx *gorm.DB) error
+	}
+
+	// SchemaMigration records when a migration has been applied.
+	SchemaMigration struct {
+		Version   uint `gorm:"primaryKey;autoIncrement:false"`
+		Name      string
+		AppliedAt time.Time `gorm:"not null"`
+	}
+
+	// MigrationStatus describes a registered migration and whether it has been applied to the database.
+	MigrationStatus struct {
+		Migration
+		Applied bool
+		// AppliedAt is nil for migrations applied before the history was recorded, or not applied at all
+		AppliedAt *time.Time
+	}
+)
+
+// migrations is the ordered registry of all schema migrations. Versions 2 to 5 predate the registry and are covered
+// by the initial schema. New migrations must be appended with a higher version.
+//
+// Tables and columns of the models are additionally created by AutoMigrate after all migrations ran. Anything that
+// AutoMigrate can't do safely on its own, like filling new columns or rebuilding tables, needs a migration here.
+var migrations = []Migration{
+	{Version: 1, Name: "initial schema", Up: func(tx *gorm.DB) error {
+		return tx.Migrator().AutoMigrate(&initialUser{}, &initialUserCookie{}, &initialKnownEntry{})
+	}},
+	{Version: 6, Name: "add invalid credentials timestamp", Up: addColumn(&User{}, "invalid_credentials_sent_at")},
+	{Version: 7, Name: "add per-user update interval", Up: addColumn(&User{}, "update_interval_seconds")},
+	{Version: 8, Name: "add blocked tag mode", Up: addColumn(&User{}, "blocked_tag_mode")},
+	{Version: 9, Name: "add entry type ratings", Up: addColumn(&UserEntryType{}, "ratings")},
+	{Version: 10, Name: "add known entry pruning cutoff", Up: addColumn(&UserEntryType{}, "pruned_before")},
+	{Version: 11, Name: "make known entries unique per user", Up: rebuildKnownEntries},
+}
+
+// The initial schema is frozen as snapshots of the models at the time, so the first migration creates the same tables no
+// matter how the models change later. Later migrations expect exactly these tables.
+type (
+	initialUser struct {
+		gorm.Model
+		TelegramChatId           int64                  `gorm:"uniqueIndex"`
+		UnreadNotesOnly          bool                   `gorm:"default:true;not null"`
+		KnownEntries             []initialKnownEntry    `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE;"`
+		Cookies                  []initialUserCookie    `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE;"`
+		EntryTypes               []initialUserEntryType `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE;"`
+		Timezone                 string                 `gorm:"default:'UTC';not null"`
+		InvalidCredentialsSentAt *time.Time
+	}
+
+	initialUserCookie struct {
+		UserID uint   `gorm:"primaryKey;autoIncrement:false;not null"`
+		Name   string `gorm:"primaryKey;not null"`
+		Value  string
+	}
+
+	// initialUserEntryType is only referenced by the user, its table has been created by AutoMigrate
+	initialUserEntryType struct {
+		UserID    uint              `gorm:"primaryKey:type_per_user;autoIncrement:false;not null"`
+		EntryType entries.EntryType `gorm:"primaryKey:type_per_user;autoIncrement:false;not null"`
+		EnabledAt time.Time         `gorm:"default:current_timestamp;not null"`
+	}
+
+	initialKnownEntry struct {
+		EntryType  entries.EntryType `gorm:"primaryKey;autoIncrement:false;default:0;not null"`
+		ID         uint              `gorm:"primaryKey;autoIncrement:false;not null"`
+		UserID     uint              `gorm:"index;not null"`
+		NotifiedAt *time.Time
+		SentDate   time.Time
+	}
+)
+
+func (initialUser) TableName() string          { return "users" }
+func (initialUserCookie) TableName() string    { return "user_cookies" }
+func (initialUserEntryType) TableName() string { return "user_entry_types" }
+func (initialKnownEntry) TableName() string    { return "known_entries" }
+
+func init() {
+	if !slices.IsSortedFunc(migrations, func(a, b Migration) int { return int(a.Version) - int(b.Version) }) {
+		panic("migrations are not ordered by version")
+	}
+}
+
+// addColumn creates a migration adding the column of the model, if it doesn't exist yet. Missing tables are skipped,
+// as AutoMigrate creates them including the column.
+func addColumn(model any, column string) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		if !tx.Migrator().HasTable(model) || tx.Migrator().HasColumn(model, column) {
+			return nil
 		}
+		return tx.Migrator().AddColumn(model, column)
+	}
+}
+
+// rebuildKnownEntries recreates the known entries table with the user as part of its primary key. Previously, an entry
+// could only be known by a single user. Neither SQLite nor all other databases can change a primary key in place, so
+// the table is copied.
+func rebuildKnownEntries(tx *gorm.DB) error {
+	const table, newTable = "known_entries", "known_entries_new"
+	migrator := tx.Migrator()
+	if !migrator.HasTable(table) {
+		return nil
 	}
+	if err := tx.Table(newTable).Migrator().CreateTable(&KnownEntry{}); err != nil {
+		return err
+	}
+	err := tx.Exec("INSERT INTO " + newTable + " (user_id, entry_type, id, notified_at, sent_date) " +
+		"SELECT user_id, entry_type, id, notified_at, sent_date FROM " + table).Error
+	if err != nil {
+		return err
+	}
+	if err = migrator.DropTable(table); err != nil {
+		return err
+	}
+	return migrator.RenameTable(newTable, table)
+}
+
+// LatestSchemaVersion returns the version of the last registered migration.
+func LatestSchemaVersion() uint {
+	return migrations[len(migrations)-1].Version
+}
+
+// SchemaVersion returns the version of the database schema, which is 0 for a new database.
+func SchemaVersion() uint {
+	return schemaVersion(Db())
+}
 
+func schemaVersion(tx *gorm.DB) uint {
+	migrator := tx.Migrator()
 	schemaInfo := SchemaInfo{}
-	db.First(&schemaInfo)
+	if migrator.HasTable(&SchemaInfo{}) && tx.Limit(1).Find(&schemaInfo).RowsAffected > 0 {
+		return schemaInfo.Version
+	}
+	if migrator.HasTable(&User{}) {
+		// Databases created before versioning was introduced
+		return 1
+	}
+	return 0
+}
+
+// PendingMigrations returns the migrations that have not been applied to the database yet.
+func PendingMigrations() []Migration {
+	return pendingMigrations(Db())
+}
+
+func pendingMigrations(tx *gorm.DB) []Migration {
+	version := schemaVersion(tx)
+	return slices.DeleteFunc(slices.Clone(migrations), func(m Migration) bool {
+		return m.Version <= version
+	})
+}
+
+// MigrationStatuses returns all registered migrations along with their recorded history.
+func MigrationStatuses() []MigrationStatus {
+	version := SchemaVersion()
+	history := make(map[uint]SchemaMigration)
+	if Db().Migrator().HasTable(&SchemaMigration{}) {
+		records := make([]SchemaMigration, 0)
+		Db().Find(&records)
+		for _, record := range records {
+			history[record.Version] = record
+		}
+	}
+
+	statuses := make([]MigrationStatus, len(migrations))
+	for i, migration := range migrations {
+		statuses[i] = MigrationStatus{Migration: migration, Applied: migration.Version <= version}
+		if record, found := history[migration.Version]; found {
+			statuses[i].AppliedAt = &record.AppliedAt
+		}
+	}
+	return statuses
+}
 
-	migrateV6(migrator, &schemaInfo)
+// Migrate applies all pending migrations in order and returns them. In dry-run mode, the pending migrations are only
+// returned without touching the database.
+func Migrate(dryRun bool) ([]Migration, error) {
+	if dryRun {
+		return PendingMigrations(), nil
+	}
+
+	var applied []Migration
+	// All statements have to run on the same connection, as the migration lock is bound to it
+	err := Db().Connection(func(conn *gorm.DB) error {
+		// A new session, as statements chained onto the connection would otherwise share their state
+		conn = conn.Session(&gorm.Session{})
+		unlock, err := lockMigrations(conn)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+		applied, err = applyMigrations(conn)
+		return err
+	})
+	return applied, err
 }
 
-func migrateV6(migrator gorm.Migrator, si *SchemaInfo) {
-	if si.Version >= 6 {
-		return
+func applyMigrations(conn *gorm.DB) ([]Migration, error) {
+	// Another instance might have applied some migrations while waiting for the lock
+	pending := pendingMigrations(conn)
+
+	err := conn.Migrator().AutoMigrate(&SchemaInfo{}, &SchemaMigration{})
+	if err != nil {
+		return nil, fmt.Errorf("error creating migration tables: %w", err)
 	}
 
-	invalidCredsCol := "invalid_credentials_sent_at"
-	if !migrator.HasColumn(&User{}, invalidCredsCol) {
-		err := migrator.AddColumn(&User{}, invalidCredsCol)
+	for i, migration := range pending {
+		logging.Infof("Applying migration %d (%s)", migration.Version, migration.Name)
+		err = conn.Transaction(func(tx *gorm.DB) error {
+			if err := migration.Up(tx); err != nil {
+				return err
+			}
+			return recordMigration(tx, migration)
+		})
 		if err != nil {
-			panic(err)
+			return pending[:i], fmt.Errorf("error applying migration %d (%s): %w", migration.Version, migration.Name, err)
 		}
 	}
+	return pending, nil
+}
+
+// migrationLockId is an arbitrary key for the Postgres advisory lock held while migrating
+const migrationLockId = 0x666e6d69
+
+// lockMigrations prevents multiple instances sharing a database from migrating it at the same time. SQLite databases
+// are only used by a single instance, so no lock is needed.
+func lockMigrations(conn *gorm.DB) (func(), error) {
+	if conn.Dialector.Name() != string(DriverPostgres) {
+		return func() {}, nil
+	}
+	if err := conn.Exec("SELECT pg_advisory_lock(?)", migrationLockId).Error; err != nil {
+		return nil, fmt.Errorf("error acquiring migration lock: %w", err)
+	}
+	return func() {
+		if err := conn.Exec("SELECT pg_advisory_unlock(?)", migrationLockId).Error; err != nil {
+			logging.Errorf("Error releasing migration lock: %v", err)
+		}
+	}, nil
+}
 
-	err := updateSchemaVersion(6)
+func recordMigration(tx *gorm.DB, migration Migration) error {
+	err := tx.Create(&SchemaMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}).Error
 	if err != nil {
-		panic(err)
+		return err
 	}
+
+	var count int64
+	tx.Model(&SchemaInfo{}).Count(&count)
+	if count == 0 {
+		return tx.Create(&SchemaInfo{Version: migration.Version}).Error
+	}
+	return tx.Session(&gorm.Session{AllowGlobalUpdate: true}).
+		Model(&SchemaInfo{}).
+		Update("version", migration.Version).Error
 }
 
-func updateSchemaVersion(toVersion uint) error {
-	tx := Db().Session(&gorm.Session{AllowGlobalUpdate: true}).Begin()
-	tx.Model(&SchemaInfo{}).Update("version", toVersion)
-	tx.Commit()
-	return tx.Error
+func migrate() {
+	applied, err := Migrate(false)
+	if err != nil {
+		panic(err)
+	}
+	if len(applied) > 0 {
+		logging.Infof("Migrated database to schema version %d", LatestSchemaVersion())
+	}
 }
@@ -0,0 +1,40 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrate_NewDatabase(t *testing.T) {
+	useDatabase(t, connectionConfig{
+		driver:       DriverSqlite,
+		dsn:          filepath.Join(t.TempDir(), "test.db"),
+		maxOpenConns: 1,
+		maxIdleConns: 1,
+	})
+
+	// The migrations alone have to build a working schema, starting from the frozen initial one
+	applied, err := Migrate(false)
+	require.NoError(t, err)
+	assert.Len(t, applied, len(migrations))
+	assert.Equal(t, LatestSchemaVersion(), SchemaVersion())
+
+	migrator := Db().Migrator()
+	for _, column := range []string{"telegram_chat_id", "invalid_credentials_sent_at", "update_interval_seconds", "blocked_tag_mode"} {
+		assert.True(t, migrator.HasColumn(&User{}, column), "users should have column %s", column)
+	}
+	assert.True(t, migrator.HasTable(&UserCookie{}))
+	assert.False(t, migrator.HasTable(&UserBlockedTag{}), "tables of later models are created by AutoMigrate")
+
+	require.NoError(t, Db().Create(&KnownEntry{UserID: 1, EntryType: 1, ID: 42, SentDate: time.Now()}).Error)
+	require.NoError(t, Db().Create(&KnownEntry{UserID: 2, EntryType: 1, ID: 42, SentDate: time.Now()}).Error)
+
+	// AutoMigrate completes the schema afterwards
+	CreateDatabase()
+	assert.True(t, migrator.HasTable(&UserBlockedTag{}))
+	assert.True(t, migrator.HasColumn(&UserEntryType{}, "pruned_before"))
+}
@@ -0,0 +1,82 @@
+package db
+
+import (
+	"strings"
+	"time"
+
+	"github.com/senexdrake/furaffinity-notifier/internal/fa/entries"
+	"gorm.io/gorm"
+)
+
+type (
+	// NotificationRecord is a compact record of a delivered notification, so users can find entries they have been
+	// notified about later on.
+	NotificationRecord struct {
+		ID        uint              `gorm:"primaryKey"`
+		UserID    uint              `gorm:"index:idx_notification_records_user_time,priority:1;not null"`
+		EntryType entries.EntryType `gorm:"not null"`
+		EntryID   uint              `gorm:"not null"`
+		Title     string
+		// Author is the username of the FA user the entry is from
+		Author string
+		Date   time.Time
+		Link   string
+		// MessageID is the ID of the Telegram message of the notification
+		MessageID  int
+		NotifiedAt time.Time `gorm:"index:idx_notification_records_user_time,priority:2;not null"`
+	}
+
+	// NotificationQuery selects notification records. Empty fields match all records.
+	NotificationQuery struct {
+		EntryTypes []entries.EntryType
+		// Author matches the username of the FA user exactly, ignoring case
+		Author string
+		// Text matches any part of the title or author, ignoring case
+		Text string
+	}
+)
+
+// RecordNotification stores a record of a delivered notification.
+func RecordNotification(record *NotificationRecord) error {
+	return Db().Create(record).Error
+}
+
+// FindNotifications returns a page of the notification records of the user matching the query, latest first, along
+// with the total number of matching records.
+func FindNotifications(userId uint, query NotificationQuery, offset int, limit int) ([]NotificationRecord, int64, error) {
+	tx := Db().Model(&NotificationRecord{}).Where(&NotificationRecord{UserID: userId})
+	if len(query.EntryTypes) > 0 {
+		tx = tx.Where("entry_type IN ?", query.EntryTypes)
+	}
+	if query.Author != "" {
+		tx = tx.Where("LOWER(author) = ?", strings.ToLower(query.Author))
+	}
+	if query.Text != "" {
+		// LIKE ignores case only in SQLite, so both sides are lowercased
+		pattern := "%" + escapeLike(strings.ToLower(query.Text)) + "%"
+		tx = tx.Where("(LOWER(title) LIKE ? ESCAPE '\\' OR LOWER(author) LIKE ? ESCAPE '\\')", pattern, pattern)
+	}
+
+	// A new session, so counting doesn't leak into the query of the page
+	tx = tx.Session(&gorm.Session{})
+	total := int64(0)
+	if err := tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	records := make([]NotificationRecord, 0, limit)
+	err := tx.Order("notified_at DESC, id DESC").Offset(offset).Limit(limit).Find(&records).Error
+	return records, total, err
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// escapeLike escapes the wildcards of a LIKE pattern, so the text is matched literally.
+func escapeLike(text string) string {
+	return likeEscaper.Replace(text)
+}
+
+func (nr *NotificationRecord) BeforeSave(tx *gorm.DB) error {
+	nr.Date = nr.Date.UTC()
+	nr.NotifiedAt = nr.NotifiedAt.UTC()
+	return nil
+}
@@ -0,0 +1,90 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/senexdrake/furaffinity-notifier/internal/fa/entries"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindNotifications(t *testing.T) {
+	useSqliteDatabase(t)
+	user := User{TelegramChatId: 1}
+	other := User{TelegramChatId: 2}
+	require.NoError(t, Db().Create(&user).Error)
+	require.NoError(t, Db().Create(&other).Error)
+
+	now := time.Now()
+	records := []NotificationRecord{
+		{UserID: user.ID, EntryType: entries.EntryTypeSubmission, EntryID: 1, Title: "Red Dragon", Author: "artist"},
+		{UserID: user.ID, EntryType: entries.EntryTypeSubmission, EntryID: 2, Title: "Blue dragon", Author: "painter"},
+		{UserID: user.ID, EntryType: entries.EntryTypeJournal, EntryID: 3, Title: "100% done", Author: "Artist"},
+		{UserID: user.ID, EntryType: entries.EntryTypeSubmissionComment, EntryID: 4, Title: "Red_Dragon", Author: "fan"},
+		{UserID: other.ID, EntryType: entries.EntryTypeSubmission, EntryID: 1, Title: "Red Dragon", Author: "artist"},
+	}
+	for i := range records {
+		records[i].NotifiedAt = now.Add(time.Duration(i) * time.Minute)
+		require.NoError(t, RecordNotification(&records[i]))
+	}
+
+	tests := []struct {
+		name     string
+		query    NotificationQuery
+		expected []uint
+	}{
+		{name: "all", expected: []uint{4, 3, 2, 1}},
+		{name: "type", query: NotificationQuery{EntryTypes: []entries.EntryType{entries.EntryTypeSubmission}}, expected: []uint{2, 1}},
+		{name: "author ignores case", query: NotificationQuery{Author: "ARTIST"}, expected: []uint{3, 1}},
+		{name: "type and author", query: NotificationQuery{
+			EntryTypes: []entries.EntryType{entries.EntryTypeJournal},
+			Author:     "artist",
+		}, expected: []uint{3}},
+		{name: "text ignores case", query: NotificationQuery{Text: "DRAGON"}, expected: []uint{4, 2, 1}},
+		{name: "text matches author", query: NotificationQuery{Text: "paint"}, expected: []uint{2}},
+		{name: "wildcards are literal", query: NotificationQuery{Text: "0%"}, expected: []uint{3}},
+		{name: "underscore is literal", query: NotificationQuery{Text: "red_"}, expected: []uint{4}},
+		{name: "no match", query: NotificationQuery{Text: "cat"}, expected: []uint{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found, total, err := FindNotifications(user.ID, tt.query, 0, 10)
+			require.NoError(t, err)
+			assert.Equal(t, int64(len(tt.expected)), total)
+			ids := make([]uint, len(found))
+			for i, record := range found {
+				ids[i] = record.EntryID
+			}
+			assert.Equal(t, tt.expected, ids)
+		})
+	}
+}
+
+func TestFindNotifications_Pages(t *testing.T) {
+	useSqliteDatabase(t)
+	user := User{TelegramChatId: 1}
+	require.NoError(t, Db().Create(&user).Error)
+	now := time.Now()
+	for i := range 5 {
+		require.NoError(t, RecordNotification(&NotificationRecord{
+			UserID:     user.ID,
+			EntryType:  entries.EntryTypeNote,
+			EntryID:    uint(i + 1),
+			NotifiedAt: now.Add(time.Duration(i) * time.Minute),
+		}))
+	}
+
+	page, total, err := FindNotifications(user.ID, NotificationQuery{}, 2, 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), total)
+	require.Len(t, page, 2)
+	assert.Equal(t, uint(3), page[0].EntryID)
+	assert.Equal(t, uint(2), page[1].EntryID)
+
+	page, _, err = FindNotifications(user.ID, NotificationQuery{}, 4, 2)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, uint(1), page[0].EntryID)
+}
@@ -0,0 +1,17 @@
+package db
+
+// RatingMask is a bit set of the content ratings a user wants to be notified about. Bit n stands for the rating with
+// the value n, following the order general, mature, adult.
+type RatingMask uint8
+
+const RatingMaskAll RatingMask = 0b111
+
+// Allows returns true if the rating with the given value is part of the mask.
+func (m RatingMask) Allows(rating uint8) bool {
+	return m&(1<<rating) != 0
+}
+
+// Toggle returns the mask with the rating with the given value added or removed.
+func (m RatingMask) Toggle(rating uint8) RatingMask {
+	return m ^ (1 << rating)
+}
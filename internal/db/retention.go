@@ -0,0 +1,96 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RetentionPolicy defines which known entries may be pruned. Pruned entries are never notified again, because the
+// cutoff is recorded for each user and entry type, and entries dated before it are not considered valid anymore.
+// Entries younger than minPruneAge are always kept.
+type RetentionPolicy struct {
+	// MaxAge prunes entries sent longer ago than this. Zero keeps entries regardless of their age.
+	MaxAge time.Duration
+	// KeepLatest always keeps this number of the latest entries per entry type. Zero keeps no minimum.
+	KeepLatest int
+}
+
+// minPruneAge is the minimum age of pruned entries, regardless of the policy. Entries can still show up in FA inboxes
+// weeks after they have been posted, for example when a user is followed later on, and must not be mistaken for
+// pruned ones then.
+const minPruneAge = 60 * 24 * time.Hour
+
+// Enabled returns true if the policy prunes any entries at all.
+func (rp RetentionPolicy) Enabled() bool {
+	return rp.MaxAge > 0 || rp.KeepLatest > 0
+}
+
+// PruneKnownEntries removes the known entries of the user that fall outside the retention policy and returns the
+// number of removed entries. Entries without a date are never pruned, as they could not be told apart from new ones.
+func PruneKnownEntries(userId uint, policy RetentionPolicy, now time.Time) (int64, error) {
+	if !policy.Enabled() {
+		return 0, nil
+	}
+
+	entryTypes := make([]UserEntryType, 0)
+	if err := Db().Where(&UserEntryType{UserID: userId}).Find(&entryTypes).Error; err != nil {
+		return 0, err
+	}
+
+	pruned := int64(0)
+	for _, entryType := range entryTypes {
+		err := Db().Transaction(func(tx *gorm.DB) error {
+			cutoff, found := pruneCutoff(tx, &entryType, policy, now)
+			if !found {
+				return nil
+			}
+			result := tx.Where(&KnownEntry{UserID: userId, EntryType: entryType.EntryType}).
+				Where("sent_date > ? AND sent_date < ?", time.Time{}, cutoff).
+				Delete(&KnownEntry{})
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+			pruned += result.RowsAffected
+			// Record the cutoff in the same transaction, so the pruned entries can't be mistaken for new ones afterwards
+			if entryType.PrunedBefore == nil || cutoff.After(*entryType.PrunedBefore) {
+				return tx.Model(&UserEntryType{UserID: userId, EntryType: entryType.EntryType}).
+					Update("pruned_before", cutoff).Error
+			}
+			return nil
+		})
+		if err != nil {
+			return pruned, err
+		}
+	}
+	return pruned, nil
+}
+
+// pruneCutoff returns the date before which entries of the given type are pruned. If both limits of the policy are
+// set, only entries outside both of them are pruned. The cutoff is never later than the minimum age allows.
+func pruneCutoff(tx *gorm.DB, entryType *UserEntryType, policy RetentionPolicy, now time.Time) (time.Time, bool) {
+	var cutoff time.Time
+	if policy.MaxAge > 0 {
+		cutoff = now.Add(-policy.MaxAge).UTC()
+	}
+	if policy.KeepLatest > 0 {
+		var latest []KnownEntry
+		tx.Where(&KnownEntry{UserID: entryType.UserID, EntryType: entryType.EntryType}).
+			Where("sent_date > ?", time.Time{}).
+			Order("sent_date DESC").
+			Offset(policy.KeepLatest - 1).
+			Limit(1).
+			Find(&latest)
+		if len(latest) == 0 {
+			// Fewer entries than should be kept
+			return cutoff, false
+		}
+		if cutoff.IsZero() || latest[0].SentDate.Before(cutoff) {
+			cutoff = latest[0].SentDate.UTC()
+		}
+	}
+	if latestCutoff := now.Add(-minPruneAge).UTC(); cutoff.After(latestCutoff) {
+		cutoff = latestCutoff
+	}
+	return cutoff, true
+}
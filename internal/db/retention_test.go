@@ -0,0 +1,95 @@
+package db
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/senexdrake/furaffinity-notifier/internal/fa/entries"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPruneKnownEntries(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	tests := []struct {
+		name         string
+		policy       RetentionPolicy
+		remaining    []uint
+		prunedBefore *time.Time
+	}{
+		{"disabled", RetentionPolicy{}, []uint{1, 2, 3, 4, 5}, nil},
+		{"max age", RetentionPolicy{MaxAge: 100 * day}, []uint{1, 4, 5}, new(now.Add(-100 * day))},
+		{"keep latest", RetentionPolicy{KeepLatest: 2}, []uint{1, 4, 5}, new(now.Add(-90 * day))},
+		{"keep latest beyond max age", RetentionPolicy{MaxAge: 100 * day, KeepLatest: 3}, []uint{1, 3, 4, 5}, new(now.Add(-120 * day))},
+		{"keep more than known", RetentionPolicy{KeepLatest: 10}, []uint{1, 2, 3, 4, 5}, nil},
+		{"max age below minimum age", RetentionPolicy{MaxAge: 10 * day}, []uint{1, 5}, new(now.Add(-minPruneAge))},
+		{"keep latest below minimum age", RetentionPolicy{KeepLatest: 1}, []uint{1, 5}, new(now.Add(-minPruneAge))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useSqliteDatabase(t)
+			user := User{TelegramChatId: 1}
+			require.NoError(t, Db().Create(&user).Error)
+			user.EnableEntryType(entries.EntryTypeSubmission, true, nil)
+
+			knownEntries := []KnownEntry{
+				// Entries without a date are never pruned
+				{ID: 1, SentDate: time.Time{}},
+				{ID: 2, SentDate: now.Add(-150 * day)},
+				{ID: 3, SentDate: now.Add(-120 * day)},
+				{ID: 4, SentDate: now.Add(-90 * day)},
+				{ID: 5, SentDate: now.Add(-day)},
+			}
+			for i := range knownEntries {
+				knownEntries[i].UserID = user.ID
+				knownEntries[i].EntryType = entries.EntryTypeSubmission
+			}
+			require.NoError(t, Db().Create(&knownEntries).Error)
+
+			pruned, err := PruneKnownEntries(user.ID, tt.policy, now)
+			require.NoError(t, err)
+			assert.EqualValues(t, len(knownEntries)-len(tt.remaining), pruned)
+
+			remaining := make([]uint, 0)
+			Db().Model(&KnownEntry{}).Where(&KnownEntry{UserID: user.ID}).Pluck("id", &remaining)
+			slices.Sort(remaining)
+			assert.Equal(t, tt.remaining, remaining)
+
+			user.EntryTypes = nil
+			prunedBefore := user.EntryTypeStatus()[entries.EntryTypeSubmission].PrunedBefore
+			if tt.prunedBefore == nil {
+				assert.Nil(t, prunedBefore)
+			} else if assert.NotNil(t, prunedBefore) {
+				assert.True(t, tt.prunedBefore.Equal(*prunedBefore), "expected %s, got %s", tt.prunedBefore, prunedBefore)
+			}
+		})
+	}
+}
+
+func TestPruneKnownEntries_OnlyRecentEntries(t *testing.T) {
+	useSqliteDatabase(t)
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	user := User{TelegramChatId: 1}
+	require.NoError(t, Db().Create(&user).Error)
+	user.EnableEntryType(entries.EntryTypeSubmission, true, nil)
+	for i := range 5 {
+		require.NoError(t, Db().Create(&KnownEntry{
+			UserID:    user.ID,
+			EntryType: entries.EntryTypeSubmission,
+			ID:        uint(i + 1),
+			SentDate:  now.Add(-time.Duration(i) * time.Hour),
+		}).Error)
+	}
+
+	// The latest entry is only hours old, which must not become the cutoff for entries showing up late
+	pruned, err := PruneKnownEntries(user.ID, RetentionPolicy{KeepLatest: 1}, now)
+	require.NoError(t, err)
+	assert.Zero(t, pruned)
+
+	user.EntryTypes = nil
+	assert.Nil(t, user.EntryTypeStatus()[entries.EntryTypeSubmission].PrunedBefore)
+}
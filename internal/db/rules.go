@@ -0,0 +1,72 @@
+package db
+
+import (
+	"github.com/fanonwue/goutils/logging"
+	"github.com/senexdrake/furaffinity-notifier/internal/fa/rules"
+	"gorm.io/gorm"
+)
+
+// UserRule is a content rule of a user, deciding which submissions are sent based on their title, description, tags
+// and other details.
+type UserRule struct {
+	ID      uint         `gorm:"primaryKey"`
+	UserID  uint         `gorm:"index;not null"`
+	Action  rules.Action `gorm:"not null"`
+	Field   rules.Field  `gorm:"not null"`
+	Pattern string       `gorm:"not null"`
+	Regex   bool         `gorm:"default:false;not null"`
+}
+
+// ContentRules returns the rules of the user in the order they were added.
+func (u *User) ContentRules() []UserRule {
+	userRules := u.Rules
+	if userRules == nil {
+		Db().Where(&UserRule{UserID: u.ID}).Order("id").Find(&userRules)
+	}
+	return userRules
+}
+
+// RuleSet compiles the rules of the user. Rules that can't be compiled anymore are skipped.
+func (u *User) RuleSet() *rules.RuleSet {
+	compiled := make([]*rules.Rule, 0, len(u.ContentRules()))
+	for _, userRule := range u.ContentRules() {
+		rule, err := userRule.Rule()
+		if err != nil {
+			logging.Warnf("Skipping invalid rule %d of user %d: %s", userRule.ID, u.ID, err)
+			continue
+		}
+		compiled = append(compiled, rule)
+	}
+	return rules.NewRuleSet(compiled...)
+}
+
+func (u *User) AddRule(rule *rules.Rule, tx *gorm.DB) (*UserRule, error) {
+	if tx == nil {
+		tx = Db()
+	}
+	userRule := UserRule{
+		UserID:  u.ID,
+		Action:  rule.Action,
+		Field:   rule.Field,
+		Pattern: rule.Pattern,
+		Regex:   rule.Regex,
+	}
+	err := tx.Create(&userRule).Error
+	if err != nil {
+		return nil, err
+	}
+	return &userRule, nil
+}
+
+// RemoveRule removes the rule with the given ID. It returns false if the user has no such rule.
+func (u *User) RemoveRule(id uint, tx *gorm.DB) (bool, error) {
+	if tx == nil {
+		tx = Db()
+	}
+	result := tx.Where(&UserRule{UserID: u.ID}).Delete(&UserRule{ID: id})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (ur *UserRule) Rule() (*rules.Rule, error) {
+	return rules.Compile(ur.Action, ur.Field, ur.Pattern, ur.Regex)
+}
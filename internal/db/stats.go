@@ -0,0 +1,142 @@
+package db
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/senexdrake/furaffinity-notifier/internal/fa/entries"
+)
+
+type (
+	// StatsPeriod is a time span ending now that statistics are computed for.
+	StatsPeriod struct {
+		Name   string
+		Length time.Duration
+	}
+
+	// PeriodStats counts the notifications of a user within a period.
+	PeriodStats struct {
+		StatsPeriod
+		Total      int
+		EntryTypes map[entries.EntryType]int
+		// TopArtists are the FA users the user has been notified about the most submissions and journals from
+		TopArtists []AuthorCount
+	}
+
+	// AuthorCount is the number of notifications about entries of an FA user.
+	AuthorCount struct {
+		Author string
+		Count  int
+	}
+
+	// UserStats summarizes the notifications of a user. Everything besides the periods covers the longest period.
+	UserStats struct {
+		Location *time.Location
+		Periods  []PeriodStats
+		// TopCommenters are the FA users the user has been notified about the most comments from
+		TopCommenters []AuthorCount
+		// Hours counts the notifications by the hour of the day they have been received at
+		Hours [24]int
+		// Days counts the notifications per day, oldest first. The last day is today.
+		Days []DayCount
+	}
+
+	// DayCount is the number of notifications on a day.
+	DayCount struct {
+		Date  time.Time
+		Count int
+	}
+)
+
+// StatsPeriods are the periods statistics are computed for, ordered by their length
+var StatsPeriods = []StatsPeriod{
+	{Name: "day", Length: 24 * time.Hour},
+	{Name: "week", Length: 7 * 24 * time.Hour},
+	{Name: "month", Length: 30 * 24 * time.Hour},
+}
+
+// topAuthorLimit is the number of FA users listed as top artists or commenters
+const topAuthorLimit = 5
+
+var artistEntryTypes = []entries.EntryType{entries.EntryTypeSubmission, entries.EntryTypeJournal}
+var commentEntryTypes = []entries.EntryType{entries.EntryTypeSubmissionComment, entries.EntryTypeJournalComment}
+
+// UserStatistics computes the statistics of the user's notifications, based on the notifications recorded in the
+// history. Known entries are not counted, as they include entries the user has not been notified about, e.g. because
+// they have been filtered out.
+func UserStatistics(userId uint, location *time.Location, now time.Time) (*UserStats, error) {
+	since := now.Add(-StatsPeriods[len(StatsPeriods)-1].Length).UTC()
+
+	records := make([]NotificationRecord, 0)
+	err := Db().Select("entry_type", "author", "notified_at").
+		Where(&NotificationRecord{UserID: userId}).
+		Where("notified_at >= ?", since).
+		Find(&records).Error
+	if err != nil {
+		return nil, err
+	}
+
+	stats := UserStats{Location: location, Days: statsDays(now.In(location))}
+	for _, period := range StatsPeriods {
+		periodSince := now.Add(-period.Length)
+		periodStats := PeriodStats{StatsPeriod: period, EntryTypes: make(map[entries.EntryType]int)}
+		for _, record := range records {
+			if !record.NotifiedAt.Before(periodSince) {
+				periodStats.Total++
+				periodStats.EntryTypes[record.EntryType]++
+			}
+		}
+		periodStats.TopArtists = topAuthors(records, artistEntryTypes, periodSince)
+		stats.Periods = append(stats.Periods, periodStats)
+	}
+	stats.TopCommenters = topAuthors(records, commentEntryTypes, since)
+
+	for _, record := range records {
+		notifiedAt := record.NotifiedAt.In(location)
+		stats.Hours[notifiedAt.Hour()]++
+		for i := range stats.Days {
+			if sameDay(stats.Days[i].Date, notifiedAt) {
+				stats.Days[i].Count++
+				break
+			}
+		}
+	}
+	return &stats, nil
+}
+
+// statsDays returns the days of the longest period, ending with the day of now.
+func statsDays(now time.Time) []DayCount {
+	dayCount := int(StatsPeriods[len(StatsPeriods)-1].Length / (24 * time.Hour))
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	days := make([]DayCount, dayCount)
+	for i := range days {
+		days[i].Date = today.AddDate(0, 0, i-dayCount+1)
+	}
+	return days
+}
+
+func sameDay(a time.Time, b time.Time) bool {
+	return a.Year() == b.Year() && a.YearDay() == b.YearDay()
+}
+
+// topAuthors returns the FA users with the most records of the given entry types since the given time.
+func topAuthors(records []NotificationRecord, entryTypes []entries.EntryType, since time.Time) []AuthorCount {
+	counts := make(map[string]int)
+	for _, record := range records {
+		if record.Author != "" && slices.Contains(entryTypes, record.EntryType) && !record.NotifiedAt.Before(since) {
+			counts[strings.ToLower(record.Author)]++
+		}
+	}
+
+	authors := slices.SortedFunc(maps.Keys(counts), func(a, b string) int {
+		return cmp.Or(cmp.Compare(counts[b], counts[a]), strings.Compare(a, b))
+	})
+	top := make([]AuthorCount, 0, topAuthorLimit)
+	for _, author := range authors[:min(len(authors), topAuthorLimit)] {
+		top = append(top, AuthorCount{Author: author, Count: counts[author]})
+	}
+	return top
+}
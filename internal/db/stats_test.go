@@ -0,0 +1,97 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/senexdrake/furaffinity-notifier/internal/fa/entries"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserStatistics(t *testing.T) {
+	useSqliteDatabase(t)
+	user := User{TelegramChatId: 1}
+	other := User{TelegramChatId: 2}
+	require.NoError(t, Db().Create(&user).Error)
+	require.NoError(t, Db().Create(&other).Error)
+
+	location, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	now := time.Date(2026, 6, 15, 12, 0, 0, 0, time.UTC)
+
+	deliveries := []struct {
+		userId    uint
+		entryType entries.EntryType
+		author    string
+		age       time.Duration
+	}{
+		{user.ID, entries.EntryTypeSubmission, "artist", time.Hour},
+		{user.ID, entries.EntryTypeSubmission, "Artist", 2 * time.Hour},
+		{user.ID, entries.EntryTypeJournal, "writer", 3 * 24 * time.Hour},
+		{user.ID, entries.EntryTypeSubmissionComment, "fan", 10 * 24 * time.Hour},
+		{user.ID, entries.EntryTypeNote, "friend", 20 * 24 * time.Hour},
+		{user.ID, entries.EntryTypeSubmission, "old", 40 * 24 * time.Hour},
+		{other.ID, entries.EntryTypeSubmission, "artist", time.Hour},
+	}
+	for i, d := range deliveries {
+		notifiedAt := now.Add(-d.age)
+		require.NoError(t, Db().Create(&KnownEntry{
+			UserID:     d.userId,
+			EntryType:  d.entryType,
+			ID:         uint(i + 1),
+			NotifiedAt: &notifiedAt,
+			SentDate:   notifiedAt.Add(-time.Minute),
+		}).Error)
+		require.NoError(t, RecordNotification(&NotificationRecord{
+			UserID:     d.userId,
+			EntryType:  d.entryType,
+			EntryID:    uint(i + 1),
+			Author:     d.author,
+			Date:       notifiedAt.Add(-time.Minute),
+			NotifiedAt: notifiedAt,
+		}))
+	}
+	// Entries the user has not been notified about are known as well, but must not be counted
+	dropped := now.Add(-time.Hour)
+	require.NoError(t, Db().Create(&KnownEntry{
+		UserID:     user.ID,
+		EntryType:  entries.EntryTypeSubmission,
+		ID:         100,
+		NotifiedAt: &dropped,
+		SentDate:   dropped,
+	}).Error)
+
+	stats, err := UserStatistics(user.ID, location, now)
+	require.NoError(t, err)
+	require.Len(t, stats.Periods, len(StatsPeriods))
+
+	day, week, month := stats.Periods[0], stats.Periods[1], stats.Periods[2]
+	assert.Equal(t, 2, day.Total)
+	assert.Equal(t, map[entries.EntryType]int{entries.EntryTypeSubmission: 2}, day.EntryTypes)
+	assert.Equal(t, []AuthorCount{{Author: "artist", Count: 2}}, day.TopArtists)
+
+	assert.Equal(t, 3, week.Total)
+	assert.Equal(t, []AuthorCount{{Author: "artist", Count: 2}, {Author: "writer", Count: 1}}, week.TopArtists)
+
+	assert.Equal(t, 5, month.Total)
+	assert.Equal(t, 1, month.EntryTypes[entries.EntryTypeNote])
+	assert.Equal(t, []AuthorCount{{Author: "fan", Count: 1}}, stats.TopCommenters)
+
+	// Notifications are counted by the hour they have been received at in the user's timezone, not by posting time
+	assert.Equal(t, 1, stats.Hours[13], "received at 11:00 UTC")
+	assert.Equal(t, 1, stats.Hours[12], "received at 10:00 UTC")
+	assert.Equal(t, 3, stats.Hours[14], "received at 12:00 UTC")
+	assert.Zero(t, stats.Hours[11], "posted at 09:59 UTC")
+
+	require.Len(t, stats.Days, 30)
+	today := stats.Days[len(stats.Days)-1]
+	assert.Equal(t, 15, today.Date.Day())
+	assert.Equal(t, 2, today.Count)
+	assert.Equal(t, 1, stats.Days[len(stats.Days)-4].Count)
+	total := 0
+	for _, day := range stats.Days {
+		total += day.Count
+	}
+	assert.Equal(t, month.Total, total)
+}
@@ -0,0 +1,72 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// UserExport contains all data stored about a user. The values of cookies are redacted, as they are not needed to
+// see what is stored and would allow anyone getting hold of the export to impersonate the user.
+type UserExport struct {
+	ExportedAt time.Time
+	User       *User
+}
+
+const redactedValue = "[redacted]"
+
+// userTables are all tables holding data of a user, besides the user itself
+var userTables = []any{
+	&UserCookie{},
+	&UserEntryType{},
+	&KnownEntry{},
+	&EntryClaim{},
+	&UserBlockedTag{},
+	&UserFilter{},
+	&UserRule{},
+	&UserArtistTier{},
+	&NotificationRecord{},
+}
+
+// ExportUserData collects all data stored about the user.
+func ExportUserData(userId uint) (*UserExport, error) {
+	user := User{}
+	err := Db().
+		Preload("KnownEntries", func(tx *gorm.DB) *gorm.DB { return tx.Order("entry_type, id") }).
+		Preload("Cookies").
+		Preload("EntryTypes").
+		Preload("BlockedTags").
+		Preload("Filters").
+		Preload("Rules").
+		Preload("ArtistTiers").
+		Preload("Notifications", func(tx *gorm.DB) *gorm.DB { return tx.Order("notified_at, id") }).
+		First(&user, userId).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range user.Cookies {
+		user.Cookies[i].Value = redactedValue
+	}
+	return &UserExport{ExportedAt: time.Now().UTC(), User: &user}, nil
+}
+
+// DeleteUserData permanently removes the user and all of their data. The data is deleted explicitly instead of relying
+// on the foreign key constraints, as SQLite does not enforce them by default.
+func DeleteUserData(userId uint) error {
+	return Db().Transaction(func(tx *gorm.DB) error {
+		for _, table := range userTables {
+			if err := tx.Where("user_id = ?", userId).Delete(table).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Unscoped().Delete(&User{}, userId).Error
+	})
+}
+
+// UserExists returns true if the user has not been deleted.
+func UserExists(userId uint) bool {
+	count := int64(0)
+	Db().Model(&User{}).Where("id = ?", userId).Count(&count)
+	return count > 0
+}
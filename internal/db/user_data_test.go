@@ -0,0 +1,75 @@
+package db
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/senexdrake/furaffinity-notifier/internal/fa/entries"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createUserWithData creates a user with a row in every table holding user data.
+func createUserWithData(t *testing.T, chatId int64) *User {
+	user := User{TelegramChatId: chatId, Cookies: []UserCookie{{Name: "a", Value: "secret"}}}
+	require.NoError(t, Db().Create(&user).Error)
+	user.EnableEntryType(entries.EntryTypeSubmission, true, nil)
+	require.NoError(t, user.AddBlockedTags([]string{"tag"}, nil))
+	require.NoError(t, user.SetUserFilter(entries.EntryTypeSubmission, "artist", FilterKindDeny, nil))
+	require.NoError(t, user.SetArtistTier("artist", ArtistTierPriority, nil))
+	require.NoError(t, Db().Create(&UserRule{UserID: user.ID, Pattern: "cat"}).Error)
+	require.NoError(t, Db().Create(&KnownEntry{UserID: user.ID, EntryType: entries.EntryTypeSubmission, ID: 1, SentDate: time.Now()}).Error)
+	require.NoError(t, RecordNotification(&NotificationRecord{UserID: user.ID, EntryType: entries.EntryTypeSubmission, EntryID: 1, NotifiedAt: time.Now()}))
+	_, claimed, err := ClaimEntry(user.ID, entries.EntryTypeSubmission, 2)
+	require.NoError(t, err)
+	require.True(t, claimed)
+	return &user
+}
+
+func TestExportUserData(t *testing.T) {
+	useSqliteDatabase(t)
+	user := createUserWithData(t, 1)
+
+	export, err := ExportUserData(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, user.TelegramChatId, export.User.TelegramChatId)
+	assert.Len(t, export.User.KnownEntries, 1)
+	assert.Len(t, export.User.EntryTypes, 1)
+	assert.Len(t, export.User.BlockedTags, 1)
+	assert.Len(t, export.User.Filters, 1)
+	assert.Len(t, export.User.Rules, 1)
+	assert.Len(t, export.User.ArtistTiers, 1)
+	assert.Len(t, export.User.Notifications, 1)
+	require.Len(t, export.User.Cookies, 1)
+	assert.Equal(t, "a", export.User.Cookies[0].Name)
+
+	data, err := json.Marshal(export)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "secret")
+}
+
+func TestDeleteUserData(t *testing.T) {
+	useSqliteDatabase(t)
+	user := createUserWithData(t, 1)
+	other := createUserWithData(t, 2)
+
+	require.NoError(t, DeleteUserData(user.ID))
+	assert.False(t, UserExists(user.ID))
+	assert.True(t, UserExists(other.ID))
+
+	for _, table := range userTables {
+		count := int64(0)
+		Db().Model(table).Where("user_id = ?", user.ID).Count(&count)
+		assert.Zero(t, count, "%T should not contain data of the deleted user", table)
+
+		Db().Model(table).Where("user_id = ?", other.ID).Count(&count)
+		assert.Positive(t, count, "%T should still contain data of the other user", table)
+	}
+	users := make([]uint, 0)
+	Db().Unscoped().Model(&User{}).Pluck("id", &users)
+	assert.Equal(t, []uint{other.ID}, users)
+
+	// The user can register again
+	require.NoError(t, Db().Create(&User{TelegramChatId: user.TelegramChatId}).Error)
+}
@@ -2,10 +2,12 @@ package fa
 
 import (
 	"bytes"
+	"context"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
@@ -15,6 +17,7 @@ import (
 	"github.com/senexdrake/furaffinity-notifier/internal/db"
 	"github.com/senexdrake/furaffinity-notifier/internal/fa/conf"
 	"github.com/senexdrake/furaffinity-notifier/internal/fa/entries"
+	"github.com/senexdrake/furaffinity-notifier/internal/fa/rules"
 	"github.com/senexdrake/furaffinity-notifier/internal/fa/tools"
 	"github.com/senexdrake/furaffinity-notifier/internal/util"
 )
@@ -54,8 +57,23 @@ type (
 		OnlySinceTypeEnabled        bool
 		IterateSubmissionsBackwards bool
 		RespectBlockedTags          bool
-		User                        *db.User
-		userFilters                 map[entries.EntryType]dsext.Set[string]
+		// ExtraBlockedTags are blocked in addition to the tag blocklist of the FA account, even if RespectBlockedTags
+		// is disabled
+		ExtraBlockedTags dsext.Set[string]
+		// Rules decide which submissions are sent based on their details
+		Rules             *rules.RuleSet
+		DetectMarkupDrift bool
+		DriftDumpDir      string
+		OnDrift           func(context.Context, *DriftReport)
+		User              *db.User
+		userFilters       map[entries.EntryType]dsext.Set[string]
+		userDenyFilters   map[entries.EntryType]dsext.Set[string]
+		unavailable       atomic.Pointer[UnavailableError]
+	}
+	ProbeResult struct {
+		LoggedIn bool
+		// Counters is nil if the message counters could not be read from the page header
+		Counters *MessageCounters
 	}
 	FurAffinityUser struct {
 		DisplayName string
@@ -72,6 +90,10 @@ const (
 	RatingAdult
 )
 
+func Ratings() []Rating {
+	return []Rating{RatingGeneral, RatingMature, RatingAdult}
+}
+
 func (r Rating) String() string {
 	switch r {
 	case RatingGeneral:
@@ -106,6 +128,7 @@ const faTimezone = "America/Los_Angeles"
 const faNoteSeparator = "—————————"
 const faDefaultUsername = "UNKNOWN"
 const requestTimeout = util.HttpDefaultRequestTimeout
+const probePath = "/controls/settings"
 
 var (
 	furaffinityBaseUrl, _         = url.Parse(faBaseUrl)
@@ -116,27 +139,66 @@ func (fc *FurAffinityCollector) httpClient() *http.Client {
 	cookieJar, _ := cookiejar.New(nil)
 	cookieJar.SetCookies(furaffinityBaseUrl, fc.notesCookies())
 	return &http.Client{
-		Jar: cookieJar,
+		Jar:       cookieJar,
+		Transport: requestLimiter.Transport(nil, requestTimeout),
 	}
 }
 
-func (fc *FurAffinityCollector) configuredCollector(withCookies bool) *colly.Collector {
+// HttpClient returns a client for requests to FA that are not made by a collector, e.g. to download submission files.
+// Its requests pass the process-wide request limiter, like those of the collectors.
+func HttpClient() *http.Client {
+	return &http.Client{Transport: requestLimiter.Transport(nil, requestTimeout)}
+}
+
+// configuredCollector returns a new collector for a single scrape. Pending and running requests are aborted once the
+// given context is done.
+func (fc *FurAffinityCollector) configuredCollector(ctx context.Context, withCookies bool) *colly.Collector {
 	c := colly.NewCollector(
+		colly.StdlibContext(ctx),
 		colly.UserAgent(userAgent),
 		colly.Async(true),
 		colly.MaxDepth(2),
 	)
 
 	c.Limit(&colly.LimitRule{DomainGlob: "*", Parallelism: fc.LimitConcurrency})
-	c.SetRequestTimeout(requestTimeout)
+	// The timeout is applied by the limited transport once the request has left the global request queue
+	c.SetRequestTimeout(0)
+	c.WithTransport(requestLimiter.Transport(nil, requestTimeout))
 
 	if withCookies {
 		c.SetCookies(faBaseUrl, fc.cookies())
 	}
 
+	// Error responses (status codes >= 203) skip the response callbacks and are passed to the error callbacks instead
+	c.OnResponse(func(r *colly.Response) {
+		fc.checkAvailability(r)
+	})
+	c.OnError(func(r *colly.Response, err error) {
+		fc.checkAvailability(r)
+	})
+
 	return c
 }
 
+func (fc *FurAffinityCollector) checkAvailability(r *colly.Response) {
+	unavailable := detectUnavailable(r)
+	if unavailable == nil {
+		return
+	}
+	if fc.unavailable.CompareAndSwap(nil, unavailable) {
+		logging.Warnf("%s", unavailable)
+	}
+}
+
+// Unavailable returns an UnavailableError if any request of this collector hit a maintenance page, a Cloudflare
+// challenge or a server error. Once set, it is returned for the lifetime of the collector.
+func (fc *FurAffinityCollector) Unavailable() error {
+	if unavailable := fc.unavailable.Load(); unavailable != nil {
+		return unavailable
+	}
+	return nil
+}
+
 func (fc *FurAffinityCollector) userCookies() []db.UserCookie {
 	if fc.User.Cookies != nil {
 		return fc.User.Cookies
@@ -175,9 +237,29 @@ func (fc *FurAffinityCollector) isEntryNew(entryType entries.EntryType, entryId
 	return rowCount == 0
 }
 
+// rejectEntry records an entry rejected by the rules as known without notifying the user, like dropped submissions.
+// Otherwise it would be considered new on every run, and have its content fetched again each time.
+func (fc *FurAffinityCollector) rejectEntry(entry BaseEntry) {
+	claim, claimed, err := db.ClaimEntry(fc.UserID(), entry.EntryType(), entry.ID())
+	if err != nil {
+		logging.Errorf("Error claiming rejected '%s' %d for user %d: %v", entry.EntryType().Name(), entry.ID(), fc.UserID(), err)
+		return
+	}
+	if !claimed {
+		return
+	}
+	if err = db.ConfirmEntry(claim, entry.Date()); err != nil {
+		logging.Errorf("Error recording rejected '%s' %d as known for user %d: %v", entry.EntryType().Name(), entry.ID(), fc.UserID(), err)
+	}
+}
+
 // IsWhitelisted returns true if the user is whitelisted for the given entry type or if there is no filter specified
-// for that entry type.
+// for that entry type. Denied users are never whitelisted.
 func (fc *FurAffinityCollector) IsWhitelisted(entryType entries.EntryType, user string) bool {
+	denied, found := fc.userDenyFilters[entryType]
+	if found && denied.Contains(util.NormalizeUsername(user)) {
+		return false
+	}
 	filter, found := fc.userFilters[entryType]
 	// SetUserFilter does not allow empty filters to be set, so we don't need to check for them.
 	if !found {
@@ -186,6 +268,18 @@ func (fc *FurAffinityCollector) IsWhitelisted(entryType entries.EntryType, user
 	return filter.Contains(util.NormalizeUsername(user))
 }
 
+// IsRatingAllowed returns true if the user wants to be notified about entries of the given type with the given rating.
+func (fc *FurAffinityCollector) IsRatingAllowed(entryType entries.EntryType, rating Rating) bool {
+	if fc.User == nil {
+		return true
+	}
+	status, found := fc.User.EntryTypeStatus()[entryType]
+	if !found {
+		return true
+	}
+	return status.Ratings.Allows(uint8(rating))
+}
+
 func (fc *FurAffinityCollector) SetUserFilter(entryType entries.EntryType, users []string) {
 	// If the filter is empty, remove it. Nil slice has a length of 0 too.
 	if len(users) == 0 {
@@ -195,6 +289,15 @@ func (fc *FurAffinityCollector) SetUserFilter(entryType entries.EntryType, users
 	fc.userFilters[entryType] = dsext.NewSetSlice(dsext.Map(users, util.NormalizeUsername))
 }
 
+// SetUserDenyFilter sets the users whose entries of the given type are never sent.
+func (fc *FurAffinityCollector) SetUserDenyFilter(entryType entries.EntryType, users []string) {
+	if len(users) == 0 {
+		delete(fc.userDenyFilters, entryType)
+		return
+	}
+	fc.userDenyFilters[entryType] = dsext.NewSetSlice(dsext.Map(users, util.NormalizeUsername))
+}
+
 func (fc *FurAffinityCollector) UserID() uint {
 	return fc.User.ID
 }
@@ -211,8 +314,14 @@ func (fc *FurAffinityCollector) TypeEnabledSince(entryType entries.EntryType) ti
 }
 
 // DateIsValid returns true if the given date is valid for the user. A date is valid if it is after the user's
-// registration date and if it is after the date at which the entry type was enabled for the user.
+// registration date and if it is after the date at which the entry type was enabled for the user. Dates before the
+// known entries of the type have been pruned are never valid, as those entries can't be recognized anymore.
 func (fc *FurAffinityCollector) DateIsValid(entryType entries.EntryType, date time.Time) bool {
+	if fc.User != nil {
+		if prunedBefore := fc.User.EntryTypeStatus()[entryType].PrunedBefore; prunedBefore != nil && date.Before(*prunedBefore) {
+			return false
+		}
+	}
 	if fc.OnlySinceRegistration && date.Before(fc.registrationDate()) {
 		return false
 	}
@@ -226,22 +335,55 @@ func (fc *FurAffinityCollector) channelBufferSize() int {
 	return fc.LimitConcurrency
 }
 
-func (fc *FurAffinityCollector) IsLoggedIn() (bool, error) {
-	c := fc.configuredCollector(true)
+func (fc *FurAffinityCollector) IsLoggedIn(ctx context.Context) (bool, error) {
+	result, err := fc.Probe(ctx)
+	if err != nil {
+		return false, err
+	}
+	return result.LoggedIn, nil
+}
+
+// Probe fetches a single page to check whether the user is logged in and to read the message counters from the page
+// header. This allows skipping the more expensive message pages if there is nothing new on them.
+func (fc *FurAffinityCollector) Probe(ctx context.Context) (*ProbeResult, error) {
+	c := fc.configuredCollector(ctx, true)
 	c.Async = false
 
-	loggedIn := false
+	result := ProbeResult{}
 
 	c.OnResponse(func(r *colly.Response) {
-		loggedIn = isLoggedIn(r)
+		if detectUnavailable(r) != nil {
+			// A maintenance page would otherwise look like a logged-in page without counters
+			return
+		}
+		result.LoggedIn = isLoggedIn(r)
+		if !result.LoggedIn {
+			return
+		}
+
+		doc, err := goquery.NewDocumentFromReader(bytes.NewReader(r.Body)) // A byte reader does not have to be closed
+		if err != nil {
+			logging.Errorf("Error parsing probe response: %s", err)
+			return
+		}
+		counters, found := parseMessageCounters(doc.Selection)
+		if !found {
+			logging.Warnf("No message counters found in page header for user %d", fc.UserID())
+			return
+		}
+		result.Counters = counters
 	})
 
-	err := c.Visit(faBaseUrl + "/controls/settings")
+	err := c.Visit(faBaseUrl + probePath)
+	c.Wait()
+	if unavailable := fc.Unavailable(); unavailable != nil {
+		// Prefer the typed error over colly's generic one, so callers can pause polling
+		return nil, unavailable
+	}
 	if err != nil {
-		return false, err
+		return nil, err
 	}
-	c.Wait()
-	return loggedIn, nil
+	return &result, nil
 }
 
 func NewCollector(user *db.User) *FurAffinityCollector {
@@ -251,7 +393,9 @@ func NewCollector(user *db.User) *FurAffinityCollector {
 		OnlySinceRegistration:       true,
 		OnlySinceTypeEnabled:        true,
 		IterateSubmissionsBackwards: false,
+		DetectMarkupDrift:           true,
 		userFilters:                 make(map[entries.EntryType]dsext.Set[string]),
+		userDenyFilters:             make(map[entries.EntryType]dsext.Set[string]),
 	}
 }
 
@@ -362,6 +506,10 @@ func isLoggedIn(resp *colly.Response) bool {
 		return false
 	}
 
+	return isLoggedInDocument(doc)
+}
+
+func isLoggedInDocument(doc *goquery.Document) bool {
 	pageBody := doc.Find("body")
 
 	loginMessageContainer := pageBody.Find("#site-content .notice-message")
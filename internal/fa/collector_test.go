@@ -0,0 +1,82 @@
+package fa
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/senexdrake/furaffinity-notifier/internal/db"
+	"github.com/senexdrake/furaffinity-notifier/internal/fa/entries"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMain points the database to a temporary SQLite file, as some tests record entries as known.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "fa-test")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("FN_DATABASE_PATH", filepath.Join(dir, "test.db"))
+	db.CreateDatabase()
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestIsWhitelisted(t *testing.T) {
+	c := NewCollector(nil)
+	c.SetUserFilter(entries.EntryTypeSubmission, []string{"Artist", "other"})
+	c.SetUserDenyFilter(entries.EntryTypeSubmission, []string{"other"})
+	c.SetUserDenyFilter(entries.EntryTypeJournal, []string{"spammer"})
+
+	tests := []struct {
+		name      string
+		entryType entries.EntryType
+		user      string
+		expected  bool
+	}{
+		{"allowed", entries.EntryTypeSubmission, "artist", true},
+		{"not allowed", entries.EntryTypeSubmission, "someone", false},
+		{"allowed but denied", entries.EntryTypeSubmission, "other", false},
+		{"denied", entries.EntryTypeJournal, " Spammer", false},
+		{"no allow-list", entries.EntryTypeJournal, "someone", true},
+		{"no filters", entries.EntryTypeNote, "someone", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, c.IsWhitelisted(test.entryType, test.user))
+		})
+	}
+}
+
+func TestDateIsValid(t *testing.T) {
+	registered := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	enabled := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	pruned := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	user := &db.User{EntryTypes: []db.UserEntryType{
+		{EntryType: entries.EntryTypeSubmission, EnabledAt: enabled, PrunedBefore: &pruned},
+		{EntryType: entries.EntryTypeJournal, EnabledAt: enabled},
+	}}
+	user.CreatedAt = registered
+	c := NewCollector(user)
+
+	tests := []struct {
+		name      string
+		entryType entries.EntryType
+		date      time.Time
+		expected  bool
+	}{
+		{"before registration", entries.EntryTypeJournal, registered.Add(-time.Hour), false},
+		{"before type enabled", entries.EntryTypeJournal, enabled.Add(-time.Hour), false},
+		{"after type enabled", entries.EntryTypeJournal, enabled.Add(time.Hour), true},
+		{"before pruned", entries.EntryTypeSubmission, pruned.Add(-time.Hour), false},
+		{"at pruning cutoff", entries.EntryTypeSubmission, pruned, true},
+		{"after pruned", entries.EntryTypeSubmission, pruned.Add(time.Hour), true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, c.DateIsValid(test.entryType, test.date))
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package fa
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/senexdrake/furaffinity-notifier/internal/fa/entries"
+)
+
+type (
+	// MessageCounters holds the unread counters FA shows in the page header of every page when logged in.
+	MessageCounters struct {
+		Submissions uint
+		Comments    uint
+		Journals    uint
+		Notes       uint
+		Watches     uint
+		Favorites   uint
+	}
+)
+
+// messageBarSelector matches the header container holding the message counters. It is present on every page as long
+// as the user is logged in, even when all counters are zero (in which case the counter links are omitted).
+const messageBarSelector = ".message-bar-desktop"
+const messageCounterSelector = "a.notification-container"
+
+var (
+	// Matches counter titles like "1,234 Submission Notifications" or "2 Unread Notes"
+	messageCounterTitleRegex = regexp.MustCompile("^([\\d,]+)\\s+(\\w+)")
+	// Matches counter texts like "1,234S" or "2N"
+	messageCounterTextRegex = regexp.MustCompile("^([\\d,]+)\\s*([A-Za-z])$")
+)
+
+// Total returns the sum of all counters relevant for entry types this bot knows about.
+func (mc *MessageCounters) Total() uint {
+	return mc.Submissions + mc.Comments + mc.Journals + mc.Notes
+}
+
+// HasNew returns false if the counters show that there is nothing new for the given entry type. Unknown counters
+// (a nil receiver) always return true, so callers fall back to scraping.
+func (mc *MessageCounters) HasNew(entryType entries.EntryType) bool {
+	if mc == nil {
+		return true
+	}
+	switch entryType {
+	case entries.EntryTypeNote:
+		return mc.Notes > 0
+	case entries.EntryTypeSubmission:
+		return mc.Submissions > 0
+	case entries.EntryTypeSubmissionComment, entries.EntryTypeJournalComment:
+		return mc.Comments > 0
+	case entries.EntryTypeJournal:
+		return mc.Journals > 0
+	}
+	return true
+}
+
+// parseMessageCounters parses the message counters from the page header. The second return value is false if the
+// header could not be found, in which case the counters are unknown rather than zero.
+func parseMessageCounters(doc *goquery.Selection) (*MessageCounters, bool) {
+	messageBar := doc.Find(messageBarSelector).First()
+	if messageBar.Length() == 0 {
+		return nil, false
+	}
+
+	counters := MessageCounters{}
+	messageBar.Find(messageCounterSelector).Each(func(i int, sel *goquery.Selection) {
+		count, kind, ok := parseMessageCounter(sel)
+		if !ok {
+			return
+		}
+		counters.set(kind, count)
+	})
+
+	return &counters, true
+}
+
+func parseMessageCounter(sel *goquery.Selection) (uint, string, bool) {
+	if matches := messageCounterTitleRegex.FindStringSubmatch(sel.AttrOr("title", "")); len(matches) > 2 {
+		count, err := parseCounterValue(matches[1])
+		if err == nil {
+			return count, strings.ToLower(matches[2]), true
+		}
+	}
+
+	// Fall back to the short text form, e.g. "12S"
+	if matches := messageCounterTextRegex.FindStringSubmatch(trimHtmlText(sel.Text())); len(matches) > 2 {
+		count, err := parseCounterValue(matches[1])
+		if err == nil {
+			return count, strings.ToLower(matches[2]), true
+		}
+	}
+
+	return 0, "", false
+}
+
+func parseCounterValue(s string) (uint, error) {
+	value, err := strconv.ParseUint(strings.ReplaceAll(s, ",", ""), 10, 32)
+	return uint(value), err
+}
+
+func (mc *MessageCounters) set(kind string, count uint) {
+	switch kind {
+	case "submission", "submissions", "s":
+		mc.Submissions = count
+	case "comment", "comments", "c":
+		mc.Comments = count
+	case "journal", "journals", "j":
+		mc.Journals = count
+	case "unread", "note", "notes", "n":
+		mc.Notes = count
+	case "watch", "watches", "w":
+		mc.Watches = count
+	case "favorite", "favorites", "f":
+		mc.Favorites = count
+	}
+}
@@ -0,0 +1,133 @@
+package fa
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gocolly/colly/v2"
+)
+
+type (
+	UnavailableKind uint8
+
+	// UnavailableError is returned when FA does not serve the requested page at all, e.g. because of maintenance or
+	// a Cloudflare challenge. This affects all users, so callers should pause polling globally.
+	UnavailableError struct {
+		Kind       UnavailableKind
+		StatusCode int
+		Url        string
+	}
+)
+
+const (
+	UnavailableServerError UnavailableKind = iota + 1
+	UnavailableMaintenance
+	UnavailableChallenge
+)
+
+// ErrUnavailable matches every UnavailableError when used with errors.Is
+var ErrUnavailable = errors.New("FurAffinity is unavailable")
+
+var maintenancePhrases = []string{
+	"down for maintenance",
+	"offline for maintenance",
+	"undergoing maintenance",
+	"currently under maintenance",
+}
+
+func (uk UnavailableKind) String() string {
+	switch uk {
+	case UnavailableServerError:
+		return "server error"
+	case UnavailableMaintenance:
+		return "maintenance"
+	case UnavailableChallenge:
+		return "Cloudflare challenge"
+	}
+	panic(fmt.Sprintf("unreachable: unknown unavailable kind %d", uk))
+}
+
+func (e *UnavailableError) Error() string {
+	return fmt.Sprintf("FurAffinity is unavailable (%s, HTTP %d) while fetching '%s'", e.Kind, e.StatusCode, e.Url)
+}
+
+func (e *UnavailableError) Is(target error) bool {
+	return target == ErrUnavailable
+}
+
+// AsUnavailable returns the UnavailableError wrapped in err, if any.
+func AsUnavailable(err error) (*UnavailableError, bool) {
+	var unavailable *UnavailableError
+	if errors.As(err, &unavailable) {
+		return unavailable, true
+	}
+	return nil, false
+}
+
+// detectUnavailable checks whether the response is a maintenance page, a Cloudflare challenge or a server error
+// instead of the requested page. It returns nil if the response looks like a regular page.
+func detectUnavailable(r *colly.Response) *UnavailableError {
+	if r == nil || r.StatusCode == 0 {
+		// No response was received at all, this is a network error
+		return nil
+	}
+
+	kind := UnavailableKind(0)
+	switch {
+	case isChallenge(r):
+		kind = UnavailableChallenge
+	case isMaintenance(r):
+		kind = UnavailableMaintenance
+	case r.StatusCode >= http.StatusInternalServerError:
+		kind = UnavailableServerError
+	default:
+		return nil
+	}
+
+	unavailable := UnavailableError{Kind: kind, StatusCode: r.StatusCode}
+	if r.Request != nil && r.Request.URL != nil {
+		unavailable.Url = r.Request.URL.String()
+	}
+	return &unavailable
+}
+
+func isChallenge(r *colly.Response) bool {
+	if r.Headers != nil && strings.EqualFold(r.Headers.Get("cf-mitigated"), "challenge") {
+		return true
+	}
+	switch r.StatusCode {
+	case http.StatusForbidden, http.StatusTooManyRequests, http.StatusServiceUnavailable:
+	default:
+		return false
+	}
+	return bytes.Contains(r.Body, []byte("/cdn-cgi/challenge-platform/")) ||
+		bytes.Contains(r.Body, []byte("<title>Just a moment...</title>"))
+}
+
+func isMaintenance(r *colly.Response) bool {
+	if len(r.Body) == 0 {
+		return false
+	}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(r.Body)) // A byte reader does not have to be closed
+	if err != nil {
+		return false
+	}
+	if strings.Contains(strings.ToLower(doc.Find("title").Text()), "maintenance") {
+		return true
+	}
+	if doc.Find("#site-content").Length() > 0 {
+		// Regular pages may mention maintenance in user content, so only check pages without the usual layout
+		return false
+	}
+	text := strings.ToLower(doc.Find("body").Text())
+	for _, phrase := range maintenancePhrases {
+		if strings.Contains(text, phrase) {
+			return true
+		}
+	}
+	return false
+}
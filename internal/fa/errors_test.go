@@ -0,0 +1,81 @@
+package fa
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/gocolly/colly/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectUnavailable(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		headers  http.Header
+		body     string
+		expected UnavailableKind
+	}{
+		{
+			name:     "cloudflare challenge header",
+			status:   http.StatusForbidden,
+			headers:  http.Header{"Cf-Mitigated": {"challenge"}},
+			expected: UnavailableChallenge,
+		},
+		{
+			name:     "cloudflare challenge page",
+			status:   http.StatusServiceUnavailable,
+			body:     `<html><head><title>Just a moment...</title></head><body></body></html>`,
+			expected: UnavailableChallenge,
+		},
+		{
+			name:     "maintenance page",
+			status:   http.StatusOK,
+			body:     `<html><body><h1>Fur Affinity is currently down for maintenance.</h1></body></html>`,
+			expected: UnavailableMaintenance,
+		},
+		{
+			name:     "server error",
+			status:   http.StatusBadGateway,
+			body:     `<html><body>Bad Gateway</body></html>`,
+			expected: UnavailableServerError,
+		},
+		{
+			name:   "regular page mentioning maintenance",
+			status: http.StatusOK,
+			body:   `<html><body><div id="site-content">Journal: down for maintenance</div></body></html>`,
+		},
+		{
+			name:   "forbidden without challenge",
+			status: http.StatusForbidden,
+			body:   `<html><body>Forbidden</body></html>`,
+		},
+	}
+
+	requestUrl, _ := url.Parse(faBaseUrl + probePath)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := tt.headers
+			if headers == nil {
+				headers = http.Header{}
+			}
+			r := &colly.Response{
+				StatusCode: tt.status,
+				Headers:    &headers,
+				Body:       []byte(tt.body),
+				Request:    &colly.Request{URL: requestUrl},
+			}
+			unavailable := detectUnavailable(r)
+			if tt.expected == 0 {
+				assert.Nil(t, unavailable)
+				return
+			}
+			require.NotNil(t, unavailable)
+			assert.Equal(t, tt.expected, unavailable.Kind)
+			assert.True(t, errors.Is(unavailable, ErrUnavailable))
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package fa
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/fanonwue/goutils/logging"
+	"github.com/gocolly/colly/v2"
+)
+
+type (
+	PageKind uint8
+
+	// DriftReport describes a scraped page that does not look like the scrapers expect it to. This usually means FA
+	// changed its markup.
+	DriftReport struct {
+		Page       PageKind
+		Url        string
+		Problems   []string
+		Counters   *MessageCounters
+		Found      int
+		Parsed     int
+		DumpPath   string
+		DetectedAt time.Time
+	}
+
+	pageCheck struct {
+		// entrySelector matches the individual entries listed on the page
+		entrySelector string
+		// expectedEntries returns the amount of entries the page header claims to exist for this page
+		expectedEntries func(*MessageCounters) uint
+	}
+
+	// pageHealth tracks parse results of a single page visit to check them against the page once it has been scraped.
+	pageHealth struct {
+		kind      PageKind
+		report    *RunReport
+		attempted atomic.Int32
+		parsed    atomic.Int32
+	}
+
+	driftTracker struct {
+		mutex        sync.Mutex
+		cooldown     time.Duration
+		lastReported map[PageKind]time.Time
+	}
+)
+
+const (
+	PageKindSubmissions PageKind = iota + 1
+	PageKindOthers
+	PageKindNotes
+)
+
+// driftReportCooldown is the minimum time between two reports for the same page kind, so a persistent markup change
+// does not flood the creator with messages on every update run.
+const driftReportCooldown = 6 * time.Hour
+
+var pageChecks = map[PageKind]pageCheck{
+	PageKindSubmissions: {
+		entrySelector:   "#messagecenter-submissions .notifications-by-date figure",
+		expectedEntries: func(mc *MessageCounters) uint { return mc.Submissions },
+	},
+	PageKindOthers: {
+		entrySelector:   "#messages-comments-submission li, #messages-comments-journal li, #messages-journals li",
+		expectedEntries: func(mc *MessageCounters) uint { return mc.Comments + mc.Journals },
+	},
+	PageKindNotes: {
+		entrySelector:   "#notes-list .note-list-container",
+		expectedEntries: func(mc *MessageCounters) uint { return mc.Notes },
+	},
+}
+
+var drift = &driftTracker{
+	cooldown:     driftReportCooldown,
+	lastReported: make(map[PageKind]time.Time),
+}
+
+func (pk PageKind) String() string {
+	switch pk {
+	case PageKindSubmissions:
+		return "Submissions"
+	case PageKindOthers:
+		return "Others"
+	case PageKindNotes:
+		return "Notes"
+	}
+	panic(fmt.Sprintf("unreachable: unknown page kind %d", pk))
+}
+
+// record registers a single parse attempt of an entry on the page.
+func (ph *pageHealth) record(success bool) {
+	if ph == nil {
+		return
+	}
+	ph.attempted.Add(1)
+	if success {
+		ph.parsed.Add(1)
+	} else {
+		ph.report.recordParseFailure()
+	}
+}
+
+func (dt *driftTracker) shouldReport(kind PageKind, now time.Time) bool {
+	dt.mutex.Lock()
+	defer dt.mutex.Unlock()
+	last, found := dt.lastReported[kind]
+	if found && now.Sub(last) < dt.cooldown {
+		return false
+	}
+	dt.lastReported[kind] = now
+	return true
+}
+
+// watchPageHealth registers a callback on the collector that checks every scraped page of the given kind for markup
+// drift. The returned pageHealth should be used to record parse results while scraping, failures are forwarded to the
+// report of the run.
+func (fc *FurAffinityCollector) watchPageHealth(c *colly.Collector, kind PageKind, report *RunReport) *pageHealth {
+	health := &pageHealth{kind: kind, report: report}
+	if !fc.DetectMarkupDrift {
+		return health
+	}
+	c.OnScraped(func(r *colly.Response) {
+		fc.checkPageHealth(c.Context, health, r)
+	})
+	return health
+}
+
+func (fc *FurAffinityCollector) checkPageHealth(ctx context.Context, health *pageHealth, r *colly.Response) {
+	if detectUnavailable(r) != nil {
+		// Maintenance pages are served with status 200 and lack all entries, but that is not markup drift
+		return
+	}
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(r.Body)) // A byte reader does not have to be closed
+	if err != nil {
+		logging.Errorf("Error parsing %s page for health check: %s", health.kind, err)
+		return
+	}
+
+	if !isLoggedInDocument(doc) {
+		// Pages of users that are not logged in look different by design, this is handled by the login check
+		return
+	}
+
+	report := inspectPage(health.kind, doc, int(health.attempted.Load()), int(health.parsed.Load()))
+	if report == nil {
+		return
+	}
+	report.Url = r.Request.URL.String()
+
+	logging.Warnf("Possible markup drift on %s page for user %d: %v", report.Page, fc.UserID(), report.Problems)
+
+	if !drift.shouldReport(report.Page, report.DetectedAt) {
+		return
+	}
+
+	if fc.DriftDumpDir != "" {
+		dumpPath, err := saveDriftDump(fc.DriftDumpDir, report, r.Body)
+		if err != nil {
+			logging.Errorf("Error saving page dump for markup drift report: %s", err)
+		} else {
+			report.DumpPath = dumpPath
+		}
+	}
+
+	if fc.OnDrift != nil {
+		fc.OnDrift(ctx, report)
+	}
+}
+
+// inspectPage runs the structural checks for the given page kind. It returns nil if the page looks healthy.
+func inspectPage(kind PageKind, doc *goquery.Document, attempted int, parsed int) *DriftReport {
+	check := pageChecks[kind]
+	report := DriftReport{
+		Page:       kind,
+		Found:      doc.Find(check.entrySelector).Length(),
+		Parsed:     parsed,
+		DetectedAt: time.Now(),
+	}
+
+	counters, found := parseMessageCounters(doc.Selection)
+	if found {
+		report.Counters = counters
+		expected := check.expectedEntries(counters)
+		if expected > 0 && report.Found == 0 {
+			report.Problems = append(report.Problems, fmt.Sprintf(
+				"page header reports %d entries, but no elements matched '%s'", expected, check.entrySelector))
+		}
+	} else {
+		report.Problems = append(report.Problems, fmt.Sprintf(
+			"message counters not found in page header ('%s')", messageBarSelector))
+	}
+
+	if attempted > 0 && parsed == 0 {
+		report.Problems = append(report.Problems, fmt.Sprintf("none of the %d entries on the page could be parsed", attempted))
+	}
+
+	if len(report.Problems) == 0 {
+		return nil
+	}
+	return &report
+}
+
+func saveDriftDump(dir string, report *DriftReport, body []byte) (string, error) {
+	// The pages contain the private messages of the user, so they are only readable by the bot itself
+	err := os.MkdirAll(dir, 0o700)
+	if err != nil {
+		return "", err
+	}
+	fileName := fmt.Sprintf("%s-%s.html", report.Page, report.DetectedAt.UTC().Format("20060102-150405"))
+	dumpPath := filepath.Join(dir, fileName)
+	return dumpPath, os.WriteFile(dumpPath, body, 0o600)
+}
@@ -0,0 +1,110 @@
+package fa
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/senexdrake/furaffinity-notifier/internal/fa/entries"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testMessageBar = `
+<div class="message-bar-desktop">
+	<a class="notification-container inline" href="/msg/submissions/" title="1,234 Submission Notifications">1,234S</a>
+	<a class="notification-container inline" href="/msg/others/#comments" title="5 Comment Notifications">5C</a>
+	<a class="notification-container inline" href="/msg/others/#journals">2J</a>
+	<a class="notification-container inline" href="/msg/pms/" title="1 Unread Notes">1N</a>
+</div>`
+
+func testDocument(t *testing.T, body string) *goquery.Document {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader("<html><body>" + body + "</body></html>"))
+	require.NoError(t, err)
+	return doc
+}
+
+func TestParseMessageCounters(t *testing.T) {
+	counters, found := parseMessageCounters(testDocument(t, testMessageBar).Selection)
+	require.True(t, found)
+	assert.Equal(t, MessageCounters{Submissions: 1234, Comments: 5, Journals: 2, Notes: 1}, *counters)
+}
+
+func TestParseMessageCounters_NoCounters(t *testing.T) {
+	counters, found := parseMessageCounters(testDocument(t, `<div class="message-bar-desktop"></div>`).Selection)
+	require.True(t, found)
+	assert.Equal(t, uint(0), counters.Total())
+}
+
+func TestParseMessageCounters_MissingHeader(t *testing.T) {
+	_, found := parseMessageCounters(testDocument(t, `<div id="site-content"></div>`).Selection)
+	assert.False(t, found)
+}
+
+func TestInspectPage(t *testing.T) {
+	tests := []struct {
+		name      string
+		kind      PageKind
+		body      string
+		attempted int
+		parsed    int
+		problems  int
+	}{
+		{
+			name: "healthy submissions page",
+			kind: PageKindSubmissions,
+			body: testMessageBar + `<div id="messagecenter-submissions"><section class="notifications-by-date">
+				<figure></figure></section></div>`,
+			attempted: 1,
+			parsed:    1,
+		},
+		{
+			name:     "missing submission container",
+			kind:     PageKindSubmissions,
+			body:     testMessageBar + `<div id="messagecenter-new-submissions"></div>`,
+			problems: 1,
+		},
+		{
+			name:      "no entry could be parsed",
+			kind:      PageKindNotes,
+			body:      testMessageBar + `<div id="notes-list"><div class="note-list-container"></div></div>`,
+			attempted: 1,
+			problems:  1,
+		},
+		{
+			name:     "missing header",
+			kind:     PageKindOthers,
+			body:     `<div id="messages-journals"></div>`,
+			problems: 1,
+		},
+		{
+			name: "empty page with zero counters",
+			kind: PageKindOthers,
+			body: `<div class="message-bar-desktop"></div>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := inspectPage(tt.kind, testDocument(t, tt.body), tt.attempted, tt.parsed)
+			if tt.problems == 0 {
+				assert.Nil(t, report)
+				return
+			}
+			require.NotNil(t, report)
+			assert.Len(t, report.Problems, tt.problems)
+		})
+	}
+}
+
+func TestMessageCounters_HasNew(t *testing.T) {
+	counters := &MessageCounters{Comments: 3}
+	assert.True(t, counters.HasNew(entries.EntryTypeSubmissionComment))
+	assert.True(t, counters.HasNew(entries.EntryTypeJournalComment))
+	assert.False(t, counters.HasNew(entries.EntryTypeSubmission))
+	assert.False(t, counters.HasNew(entries.EntryTypeNote))
+
+	// Unknown counters must never cause a page to be skipped
+	var unknown *MessageCounters
+	assert.True(t, unknown.HasNew(entries.EntryTypeJournal))
+}
@@ -0,0 +1,203 @@
+package fa
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type (
+	// RequestLimiter limits the rate and the amount of concurrent requests to FA. A single limiter is shared by all
+	// collectors of the process, so the load on FA does not grow with the amount of users.
+	RequestLimiter struct {
+		interval time.Duration
+		slots    chan struct{}
+		mutex    sync.Mutex
+		nextSlot time.Time
+		stats    RequestStats
+	}
+
+	RequestStats struct {
+		Requests  uint64
+		Waiting   int
+		InFlight  int
+		TotalWait time.Duration
+		MaxWait   time.Duration
+	}
+
+	limitedTransport struct {
+		limiter *RequestLimiter
+		next    http.RoundTripper
+		timeout time.Duration
+	}
+
+	// releasingBody releases the limiter slot of a request once its body has been closed
+	releasingBody struct {
+		io.ReadCloser
+		once    sync.Once
+		release func()
+	}
+)
+
+const (
+	DefaultRequestsPerSecond = 2.0
+	DefaultMaxInFlight       = 4
+)
+
+var requestLimiter = NewRequestLimiter(DefaultRequestsPerSecond, DefaultMaxInFlight)
+
+// SetRequestLimits replaces the process-wide request limiter. It has to be called before any requests are made.
+func SetRequestLimits(requestsPerSecond float64, maxInFlight int) {
+	requestLimiter = NewRequestLimiter(requestsPerSecond, maxInFlight)
+}
+
+// RequestLimiterStats returns the statistics of the process-wide request limiter.
+func RequestLimiterStats() RequestStats {
+	return requestLimiter.Stats()
+}
+
+func NewRequestLimiter(requestsPerSecond float64, maxInFlight int) *RequestLimiter {
+	if requestsPerSecond <= 0 {
+		requestsPerSecond = DefaultRequestsPerSecond
+	}
+	if maxInFlight <= 0 {
+		maxInFlight = DefaultMaxInFlight
+	}
+	return &RequestLimiter{
+		interval: time.Duration(float64(time.Second) / requestsPerSecond),
+		slots:    make(chan struct{}, maxInFlight),
+	}
+}
+
+// Acquire blocks until a request may be started. The returned function has to be called once the request is done.
+func (rl *RequestLimiter) Acquire(ctx context.Context) (func(), error) {
+	start := time.Now()
+	rl.updateStats(func(stats *RequestStats) { stats.Waiting++ })
+	defer rl.updateStats(func(stats *RequestStats) { stats.Waiting-- })
+
+	select {
+	case rl.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	release := func() {
+		<-rl.slots
+		rl.updateStats(func(stats *RequestStats) { stats.InFlight-- })
+	}
+
+	now := time.Now()
+	slot := rl.reserve(now)
+	if delay := slot.Sub(now); delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			rl.cancel(slot)
+			<-rl.slots
+			return nil, ctx.Err()
+		}
+	}
+
+	wait := time.Since(start)
+	rl.updateStats(func(stats *RequestStats) {
+		stats.Requests++
+		stats.InFlight++
+		stats.TotalWait += wait
+		stats.MaxWait = max(stats.MaxWait, wait)
+	})
+	return release, nil
+}
+
+// reserve returns the point in time the caller's request may be started at, reserving it.
+func (rl *RequestLimiter) reserve(now time.Time) time.Time {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+	slot := rl.nextSlot
+	if slot.Before(now) {
+		slot = now
+	}
+	rl.nextSlot = slot.Add(rl.interval)
+	return slot
+}
+
+// cancel gives back a reserved slot that won't be used. This is only possible if no later slot has been reserved since,
+// as the requests waiting for those can't be moved forward anymore.
+func (rl *RequestLimiter) cancel(slot time.Time) {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+	if rl.nextSlot.Equal(slot.Add(rl.interval)) {
+		rl.nextSlot = slot
+	}
+}
+
+func (rl *RequestLimiter) updateStats(update func(*RequestStats)) {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+	update(&rl.stats)
+}
+
+func (rl *RequestLimiter) Stats() RequestStats {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+	return rl.stats
+}
+
+// Transport wraps the given transport so every request passes the limiter. The timeout only starts once the request
+// has left the queue, so waiting for the limiter does not cause requests to time out.
+func (rl *RequestLimiter) Transport(next http.RoundTripper, timeout time.Duration) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &limitedTransport{limiter: rl, next: next, timeout: timeout}
+}
+
+func (rs RequestStats) AverageWait() time.Duration {
+	if rs.Requests == 0 {
+		return 0
+	}
+	return rs.TotalWait / time.Duration(rs.Requests)
+}
+
+func (lt *limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	release, err := lt.limiter.Acquire(req.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	// Requests that are not made by a collector don't have a User-Agent yet
+	if req.Header.Get("User-Agent") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", userAgent)
+	}
+
+	cancel := func() {}
+	if lt.timeout > 0 {
+		var ctx context.Context
+		ctx, cancel = context.WithTimeout(req.Context(), lt.timeout)
+		req = req.WithContext(ctx)
+	}
+
+	resp, err := lt.next.RoundTrip(req)
+	if err != nil {
+		cancel()
+		release()
+		return nil, err
+	}
+
+	// Keep the slot occupied until the body has been read completely
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: func() {
+		cancel()
+		release()
+	}}
+	return resp, nil
+}
+
+func (rb *releasingBody) Close() error {
+	err := rb.ReadCloser.Close()
+	rb.once.Do(rb.release)
+	return err
+}
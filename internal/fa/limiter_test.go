@@ -0,0 +1,90 @@
+package fa
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestLimiter_Rate(t *testing.T) {
+	limiter := NewRequestLimiter(20, 10)
+	start := time.Now()
+	for range 5 {
+		release, err := limiter.Acquire(context.Background())
+		require.NoError(t, err)
+		release()
+	}
+	// The first request starts immediately, every following one has to wait for 50ms
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+	assert.Equal(t, uint64(5), limiter.Stats().Requests)
+}
+
+func TestRequestLimiter_CancelReturnsSlot(t *testing.T) {
+	limiter := NewRequestLimiter(2, 10)
+	release, err := limiter.Acquire(context.Background())
+	require.NoError(t, err)
+	release()
+
+	// Cancelled requests must not delay the following ones
+	for range 3 {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		_, err = limiter.Acquire(ctx)
+		cancel()
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	}
+
+	start := time.Now()
+	release, err = limiter.Acquire(context.Background())
+	require.NoError(t, err)
+	release()
+	assert.Less(t, time.Since(start), time.Second, "the request should take the first free slot")
+	assert.Equal(t, uint64(2), limiter.Stats().Requests)
+}
+
+func TestRequestLimiter_InFlight(t *testing.T) {
+	limiter := NewRequestLimiter(1000, 2)
+	first, err := limiter.Acquire(context.Background())
+	require.NoError(t, err)
+	_, err = limiter.Acquire(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, limiter.Stats().InFlight)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = limiter.Acquire(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	wg := sync.WaitGroup{}
+	wg.Go(func() {
+		release, err := limiter.Acquire(context.Background())
+		assert.NoError(t, err)
+		release()
+	})
+	first()
+	wg.Wait()
+	assert.Equal(t, 1, limiter.Stats().InFlight)
+}
+
+func TestRequestLimiter_Transport(t *testing.T) {
+	userAgents := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgents <- r.UserAgent()
+	}))
+	defer server.Close()
+
+	limiter := NewRequestLimiter(1000, 1)
+	client := &http.Client{Transport: limiter.Transport(nil, time.Second)}
+	resp, err := client.Get(server.URL)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	assert.Equal(t, userAgent, <-userAgents, "requests without a User-Agent should get the default one")
+	assert.Equal(t, uint64(1), limiter.Stats().Requests)
+	assert.Equal(t, 0, limiter.Stats().InFlight, "closing the body should release the slot")
+}
@@ -1,6 +1,8 @@
 package fa
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"maps"
 	"net/http"
@@ -80,20 +82,24 @@ func (fc *FurAffinityCollector) notesCookies() []*http.Cookie {
 	return dsext.Values(cookieMap)
 }
 
-func (fc *FurAffinityCollector) noteCollector() *colly.Collector {
-	c := fc.configuredCollector(false)
+func (fc *FurAffinityCollector) noteCollector(ctx context.Context) *colly.Collector {
+	c := fc.configuredCollector(ctx, false)
 	c.SetCookies(faBaseUrl, fc.notesCookies())
 	return c
 }
 
-func (fc *FurAffinityCollector) GetNotes(page uint) <-chan *NoteEntry {
+// GetNotes returns the notes listed on the given page of the notes folder. The report is complete once the channel
+// has been closed.
+func (fc *FurAffinityCollector) GetNotes(ctx context.Context, page uint) (<-chan *NoteEntry, *RunReport) {
 	var guardChannel chan struct{}
 	if fc.LimitConcurrency > 0 {
 		guardChannel = make(chan struct{}, fc.LimitConcurrency)
 	}
 	noteChannel := make(chan *NoteEntry)
+	report := newRunReport()
 
-	c := fc.noteCollector()
+	c := fc.noteCollector(ctx)
+	health := fc.watchPageHealth(c, PageKindNotes, report)
 
 	c.OnHTML("#notes-list", func(e *colly.HTMLElement) {
 		e.ForEach(".note-list-container", func(i int, e *colly.HTMLElement) {
@@ -102,6 +108,7 @@ func (fc *FurAffinityCollector) GetNotes(page uint) <-chan *NoteEntry {
 				defer func() { <-guardChannel }()
 			}
 			parsed := fc.parseNoteSummary(e)
+			health.record(parsed != nil)
 			if parsed == nil {
 				return
 			}
@@ -117,26 +124,22 @@ func (fc *FurAffinityCollector) GetNotes(page uint) <-chan *NoteEntry {
 		})
 	})
 
-	c.OnError(func(response *colly.Response, err error) {
-		logging.Errorf("Error while scraping note: %v", err)
-	})
-
 	go func() {
 		defer close(noteChannel)
-		err := c.Visit(fmt.Sprintf(faBaseUrl+notesPath+"%d/", page))
+		err := visitAndWait(c, fmt.Sprintf(faBaseUrl+notesPath+"%d/", page))
+		report.recordVisit(err)
 		if err != nil {
 			logging.Errorf("Error while scraping notes: %v", err)
 		}
-		c.Wait()
 	}()
 
-	return noteChannel
+	return noteChannel, report
 }
 
-func (fc *FurAffinityCollector) GetNewNotes() <-chan *NoteEntry {
+func (fc *FurAffinityCollector) GetNewNotes(ctx context.Context) (<-chan *NoteEntry, *RunReport) {
 	newNotes := make(chan *NoteEntry)
 
-	allNotes := fc.GetNotes(1)
+	allNotes, report := fc.GetNotes(ctx, 1)
 
 	go func() {
 		defer close(newNotes)
@@ -147,11 +150,12 @@ func (fc *FurAffinityCollector) GetNewNotes() <-chan *NoteEntry {
 		}
 	}()
 
-	return newNotes
+	return newNotes, report
 }
 
-func (fc *FurAffinityCollector) GetNewNotesWithContent() <-chan *NoteEntry {
+func (fc *FurAffinityCollector) GetNewNotesWithContent(ctx context.Context) (<-chan *NoteEntry, *RunReport) {
 	channel := make(chan *NoteEntry)
+	newNotes, report := fc.GetNewNotes(ctx)
 	go func() {
 		defer close(channel)
 		concurrencyLimit := fc.LimitConcurrency
@@ -164,7 +168,7 @@ func (fc *FurAffinityCollector) GetNewNotesWithContent() <-chan *NoteEntry {
 		guardChannel := make(chan struct{}, concurrencyLimit)
 
 		wg := sync.WaitGroup{}
-		for note := range fc.GetNewNotes() {
+		for note := range newNotes {
 			if note.WasUnread {
 				noteIds = append(noteIds, note.ID())
 			}
@@ -176,44 +180,67 @@ func (fc *FurAffinityCollector) GetNewNotesWithContent() <-chan *NoteEntry {
 					wg.Done()
 				}()
 				// Fetch note content without marking it as read, because we will do a batch operation alter
-				note.content = fc.GetNoteContent(note.ID(), false)
+				content, err := fc.GetNoteContent(ctx, note.ID(), false)
+				if ctx.Err() != nil {
+					// Don't deliver notes without content just because the run has been cancelled
+					return
+				}
+				report.recordContent(err)
+				if err != nil {
+					logging.Warnf("Failed to retrieve content for note %d: %s", note.ID(), err)
+				}
+				note.content = content
 				channel <- note
 			}()
 		}
 
 		wg.Wait()
-		err := fc.MarkUnread(noteIds...)
+		if len(noteIds) == 0 {
+			return
+		}
+		// Restore the unread state even if the run has been cancelled, otherwise the notes would stay marked as read
+		markCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), requestTimeout)
+		defer cancel()
+		err := fc.MarkUnread(markCtx, noteIds...)
+		report.recordVisit(err)
 		if err != nil {
 			logging.Errorf("Error while marking notes as unreads: %v", err)
 		}
 	}()
 
-	return channel
+	return channel, report
 }
 
-func (fc *FurAffinityCollector) GetNoteContents(notes []uint, markUnread bool) map[uint]*NoteContent {
+func (fc *FurAffinityCollector) GetNoteContents(ctx context.Context, notes []uint, markUnread bool) map[uint]*NoteContent {
 	contentMap := make(map[uint]*NoteContent, len(notes))
 	for _, note := range notes {
 		// Instruct to not mark as unread as this can be done via a batch request once
-		content := fc.GetNoteContent(note, false)
-		if content != nil {
-			contentMap[note] = content
+		content, err := fc.GetNoteContent(ctx, note, false)
+		if err != nil {
+			logging.Warnf("Failed to retrieve content for note %d: %s", note, err)
+			continue
 		}
+		contentMap[note] = content
 	}
 
 	if markUnread {
-		fc.MarkUnread(dsext.Keys(contentMap)...)
+		fc.MarkUnread(ctx, dsext.Keys(contentMap)...)
 	}
 
 	return contentMap
 }
 
-func (fc *FurAffinityCollector) GetNoteContent(note uint, markUnread bool) *NoteContent {
-	c := fc.noteCollector()
+func (fc *FurAffinityCollector) GetNoteContent(ctx context.Context, note uint, markUnread bool) (*NoteContent, error) {
+	c := fc.noteCollector(ctx)
 
-	channel := make(chan *NoteContent)
+	var content *NoteContent
 
 	c.OnHTML("#message .section-body", func(e *colly.HTMLElement) {
+		if content != nil {
+			// content has already been found
+			return
+		}
+
 		// Remove FA scam warning
 		dom := e.DOM
 		dom.Find(".noteWarningMessage").Remove()
@@ -227,31 +254,35 @@ func (fc *FurAffinityCollector) GetNoteContent(note uint, markUnread bool) *Note
 			text = trimHtmlText(textParts[0])
 		}
 
-		channel <- &NoteContent{
+		content = &NoteContent{
 			id:   note,
 			text: text,
 		}
-
-		if markUnread {
-			fc.MarkUnread(note)
-		}
 	})
 
 	link, err := noteIdToLink(note)
 	if err != nil {
-		close(channel)
-		return nil
+		return nil, err
 	}
 
-	go func() {
-		defer close(channel)
-		c.Visit(link.String())
-		c.Wait()
-	}()
-	return <-channel
+	err = visitAndWait(c, link.String())
+	if err != nil {
+		return nil, err
+	}
+	if content == nil {
+		return nil, fmt.Errorf("no content found for note %d", note)
+	}
+
+	if markUnread {
+		err = fc.MarkUnread(ctx, note)
+		if err != nil {
+			logging.Errorf("Error while marking note %d as unread: %v", note, err)
+		}
+	}
+	return content, nil
 }
 
-func (fc *FurAffinityCollector) MarkUnread(noteId ...uint) error {
+func (fc *FurAffinityCollector) MarkUnread(ctx context.Context, noteId ...uint) error {
 	if len(noteId) == 0 {
 		// No notes to mark as unread ;)
 		return nil
@@ -266,7 +297,20 @@ func (fc *FurAffinityCollector) MarkUnread(noteId ...uint) error {
 	}
 
 	postUrl, _ := FurAffinityUrl().Parse(notesPath)
-	_, err := client.PostForm(postUrl.String(), formValues)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, postUrl.String(), strings.NewReader(formValues.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := client.Do(req)
+	if err != nil {
+		return &RequestError{Url: postUrl.String(), Err: err}
+	}
+	// The body has to be closed to free up the request slot
+	err = resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return &RequestError{Url: postUrl.String(), StatusCode: resp.StatusCode, Err: errors.New(resp.Status)}
+	}
 	return err
 }
 
@@ -1,6 +1,7 @@
 package fa
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"maps"
@@ -61,6 +62,15 @@ type (
 
 const otherMessagesPath = "/msg/others/"
 
+// OtherEntryTypes returns the entry types that are listed on the "other messages" page.
+func OtherEntryTypes() []entries.EntryType {
+	return []entries.EntryType{
+		entries.EntryTypeSubmissionComment,
+		entries.EntryTypeJournal,
+		entries.EntryTypeJournalComment,
+	}
+}
+
 func (ce *CommentEntry) EntryType() entries.EntryType { return ce.entryType }
 func (ce *CommentEntry) Date() time.Time              { return ce.date }
 func (ce *CommentEntry) Link() *url.URL               { return ce.link }
@@ -109,14 +119,15 @@ func (jc *JournalContent) Text() string { return jc.text }
 
 func (je *JournalEntry) HasContent() bool { return je.Content() != nil }
 
-func (fc *FurAffinityCollector) otherCollector() *colly.Collector {
-	c := fc.configuredCollector(true)
+func (fc *FurAffinityCollector) otherCollector(ctx context.Context) *colly.Collector {
+	c := fc.configuredCollector(ctx, true)
 	return c
 }
 
 func (fc *FurAffinityCollector) entryHandlerWrapper(
 	channel chan<- Entry,
 	baseElement *colly.HTMLElement,
+	health *pageHealth,
 	perEntryFunc func(chan<- Entry, *sync.WaitGroup, *colly.HTMLElement) Entry,
 ) {
 	wg := sync.WaitGroup{}
@@ -126,6 +137,7 @@ func (fc *FurAffinityCollector) entryHandlerWrapper(
 		wg.Add(1)
 		defer wg.Done()
 		entry := perEntryFunc(channel, &wg, el)
+		health.record(entry != nil && entry.ID() != 0)
 		if entry == nil || entry.ID() == 0 {
 			return
 		}
@@ -138,10 +150,12 @@ func (fc *FurAffinityCollector) entryHandlerWrapper(
 	wg.Wait()
 }
 
-func (fc *FurAffinityCollector) getOtherEntriesUnfiltered(entryTypes ...entries.EntryType) <-chan Entry {
-	c := fc.otherCollector()
+func (fc *FurAffinityCollector) getOtherEntriesUnfiltered(ctx context.Context, entryTypes ...entries.EntryType) (<-chan Entry, *RunReport) {
+	c := fc.otherCollector(ctx)
 
 	channel := make(chan Entry)
+	report := newRunReport()
+	health := fc.watchPageHealth(c, PageKindOthers, report)
 
 	c.OnHTML("#messages-comments-submission", func(e *colly.HTMLElement) {
 		entryType := entries.EntryTypeSubmissionComment
@@ -159,6 +173,7 @@ func (fc *FurAffinityCollector) getOtherEntriesUnfiltered(entryTypes ...entries.
 		fc.entryHandlerWrapper(
 			channel,
 			e,
+			health,
 			handlerFunc,
 		)
 	})
@@ -179,6 +194,7 @@ func (fc *FurAffinityCollector) getOtherEntriesUnfiltered(entryTypes ...entries.
 		fc.entryHandlerWrapper(
 			channel,
 			e,
+			health,
 			handlerFunc,
 		)
 	})
@@ -199,6 +215,7 @@ func (fc *FurAffinityCollector) getOtherEntriesUnfiltered(entryTypes ...entries.
 		fc.entryHandlerWrapper(
 			channel,
 			e,
+			health,
 			handlerFunc,
 		)
 	})
@@ -207,31 +224,37 @@ func (fc *FurAffinityCollector) getOtherEntriesUnfiltered(entryTypes ...entries.
 
 	go func() {
 		defer close(channel)
-		c.Visit(link.String())
-		c.Wait()
+		err := visitAndWait(c, link.String())
+		report.recordVisit(err)
+		if err != nil {
+			logging.Errorf("Error while scraping other messages: %v", err)
+		}
 	}()
 
-	return channel
+	return channel, report
 }
 
-func (fc *FurAffinityCollector) GetOtherEntries(entryTypes ...entries.EntryType) <-chan Entry {
-	allEntries := fc.getOtherEntriesUnfiltered(entryTypes...)
+// GetOtherEntries returns the entries of the given types listed on the "other messages" page. The report is complete
+// once the channel has been closed.
+func (fc *FurAffinityCollector) GetOtherEntries(ctx context.Context, entryTypes ...entries.EntryType) (<-chan Entry, *RunReport) {
+	allEntries, report := fc.getOtherEntriesUnfiltered(ctx, entryTypes...)
 	filteredEntries := make(chan Entry)
 	go func() {
 		defer close(filteredEntries)
 		for entry := range allEntries {
-			if fc.IsWhitelisted(entry.EntryType(), entry.From().UserName) {
+			if fc.IsWhitelisted(entry.EntryType(), entry.From().UserName) &&
+				fc.IsRatingAllowed(entry.EntryType(), entry.Rating()) {
 				filteredEntries <- entry
 			}
 		}
 	}()
-	return filteredEntries
+	return filteredEntries, report
 }
 
-func (fc *FurAffinityCollector) GetNewOtherEntries(entryTypes ...entries.EntryType) <-chan Entry {
+func (fc *FurAffinityCollector) GetNewOtherEntries(ctx context.Context, entryTypes ...entries.EntryType) (<-chan Entry, *RunReport) {
 	newEntries := make(chan Entry)
 
-	allEntries := fc.GetOtherEntries(entryTypes...)
+	allEntries, report := fc.GetOtherEntries(ctx, entryTypes...)
 
 	go func() {
 		defer close(newEntries)
@@ -242,11 +265,12 @@ func (fc *FurAffinityCollector) GetNewOtherEntries(entryTypes ...entries.EntryTy
 		}
 	}()
 
-	return newEntries
+	return newEntries, report
 }
 
-func (fc *FurAffinityCollector) GetNewOtherEntriesWithContent(entryTypes ...entries.EntryType) <-chan Entry {
+func (fc *FurAffinityCollector) GetNewOtherEntriesWithContent(ctx context.Context, entryTypes ...entries.EntryType) (<-chan Entry, *RunReport) {
 	channel := make(chan Entry)
+	newEntries, report := fc.GetNewOtherEntries(ctx, entryTypes...)
 	go func() {
 		concurrencyLimit := fc.LimitConcurrency
 		if concurrencyLimit <= 0 {
@@ -256,7 +280,7 @@ func (fc *FurAffinityCollector) GetNewOtherEntriesWithContent(entryTypes ...entr
 		guardChannel := make(chan struct{}, concurrencyLimit)
 
 		wg := sync.WaitGroup{}
-		for entry := range fc.GetNewOtherEntries(entryTypes...) {
+		for entry := range newEntries {
 			guardChannel <- struct{}{}
 			wg.Add(1)
 			go func() {
@@ -265,8 +289,17 @@ func (fc *FurAffinityCollector) GetNewOtherEntriesWithContent(entryTypes ...entr
 					wg.Done()
 				}()
 
-				// Fetch note content without marking it as read, because we will do a batch operation alter
-				entry.SetContent(fc.GetOtherEntryContent(entry))
+				content, err := fc.GetOtherEntryContent(ctx, entry)
+				if ctx.Err() != nil {
+					// Don't deliver entries without content just because the run has been cancelled
+					return
+				}
+				report.recordContent(err)
+				if err != nil {
+					logging.Warnf("Failed to retrieve content for '%s' %d: %s", entry.EntryType().Name(), entry.ID(), err)
+				} else {
+					entry.SetContent(content)
+				}
 				channel <- entry
 			}()
 		}
@@ -275,21 +308,29 @@ func (fc *FurAffinityCollector) GetNewOtherEntriesWithContent(entryTypes ...entr
 		close(channel)
 	}()
 
-	return channel
+	return channel, report
 }
 
-func (fc *FurAffinityCollector) GetOtherEntryContent(entry Entry) EntryContent {
+func (fc *FurAffinityCollector) GetOtherEntryContent(ctx context.Context, entry Entry) (EntryContent, error) {
 	switch entry.(type) {
 	case *CommentEntry:
-		return fc.getCommentContent(entry.(*CommentEntry))
+		content, err := fc.getCommentContent(ctx, entry.(*CommentEntry))
+		if err != nil {
+			return nil, err
+		}
+		return content, nil
 	case *JournalEntry:
-		return fc.getJournalContent(entry.(*JournalEntry))
+		content, err := fc.getJournalContent(ctx, entry.(*JournalEntry))
+		if err != nil {
+			return nil, err
+		}
+		return content, nil
 	}
-	return nil
+	return nil, ErrContentUnsupported
 }
 
-func (fc *FurAffinityCollector) getCommentContent(entry *CommentEntry) *CommentContent {
-	c := fc.otherCollector()
+func (fc *FurAffinityCollector) getCommentContent(ctx context.Context, entry *CommentEntry) (*CommentContent, error) {
+	c := fc.otherCollector(ctx)
 
 	content := CommentContent{id: entry.ID()}
 
@@ -307,18 +348,19 @@ func (fc *FurAffinityCollector) getCommentContent(entry *CommentEntry) *CommentC
 		valid = len(content.text) > 0
 	})
 
-	c.Visit(entry.Link().String())
-	c.Wait()
-
+	err := visitAndWait(c, entry.Link().String())
+	if err != nil {
+		return nil, err
+	}
 	if !valid {
-		return nil
+		return nil, fmt.Errorf("no content found for comment %d", entry.ID())
 	}
 
-	return &content
+	return &content, nil
 }
 
-func (fc *FurAffinityCollector) getJournalContent(entry *JournalEntry) *JournalContent {
-	c := fc.otherCollector()
+func (fc *FurAffinityCollector) getJournalContent(ctx context.Context, entry *JournalEntry) (*JournalContent, error) {
+	c := fc.otherCollector(ctx)
 
 	content := JournalContent{id: entry.ID()}
 
@@ -331,14 +373,15 @@ func (fc *FurAffinityCollector) getJournalContent(entry *JournalEntry) *JournalC
 		valid = len(content.text) > 0
 	})
 
-	c.Visit(entry.Link().String())
-	c.Wait()
-
+	err := visitAndWait(c, entry.Link().String())
+	if err != nil {
+		return nil, err
+	}
 	if !valid {
-		return nil
+		return nil, fmt.Errorf("no content found for journal %d", entry.ID())
 	}
 
-	return &content
+	return &content, nil
 }
 
 func (fc *FurAffinityCollector) parseCommentEntry(entryType entries.EntryType, entryElement *colly.HTMLElement) (*CommentEntry, error) {
@@ -0,0 +1,171 @@
+package fa
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/gocolly/colly/v2"
+)
+
+type (
+	// RequestError is returned when a page could not be fetched from FA, either because of a network error or because
+	// FA responded with an error status.
+	RequestError struct {
+		Url        string
+		StatusCode int
+		Err        error
+	}
+
+	// RunReport collects the results of a single scrape run besides the entries themselves. All collector methods
+	// returning entries also return the report of their run, which is complete once the entry channel has been closed.
+	RunReport struct {
+		mutex   sync.Mutex
+		summary RunSummary
+		errors  []error
+	}
+
+	// RunSummary is a snapshot of the counters of a RunReport.
+	RunSummary struct {
+		// PagesFetched is the amount of pages that were fetched successfully
+		PagesFetched int
+		// HttpFailures is the amount of pages that could not be fetched
+		HttpFailures int
+		// ParseFailures is the amount of entries on listing pages that could not be parsed
+		ParseFailures int
+		// ContentFailures is the amount of entries whose content could not be retrieved
+		ContentFailures int
+	}
+)
+
+// ErrContentUnsupported is returned when retrieving the content of an entry type is not supported yet.
+var ErrContentUnsupported = errors.New("content retrieval is not supported for this entry")
+
+// maxReportedErrors limits the amount of errors a report keeps, so a run against a broken site does not pile up
+// hundreds of identical errors.
+const maxReportedErrors = 10
+
+func (e *RequestError) Error() string {
+	if e.StatusCode > 0 {
+		return fmt.Sprintf("error fetching '%s' (HTTP %d): %s", e.Url, e.StatusCode, e.Err)
+	}
+	return fmt.Sprintf("error fetching '%s': %s", e.Url, e.Err)
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+func newRunReport() *RunReport {
+	return &RunReport{}
+}
+
+// Summary returns the counters of the report.
+func (rr *RunReport) Summary() RunSummary {
+	rr.mutex.Lock()
+	defer rr.mutex.Unlock()
+	return rr.summary
+}
+
+// Err returns the errors of all pages that could not be fetched, or nil if all requests succeeded. A run without
+// errors and without entries means there was nothing new. Parse and content failures do not count as errors here,
+// as the run itself still succeeded; they are available via Summary.
+func (rr *RunReport) Err() error {
+	rr.mutex.Lock()
+	defer rr.mutex.Unlock()
+	if rr.summary.HttpFailures == 0 {
+		return nil
+	}
+	return errors.Join(rr.errors...)
+}
+
+// Failed returns true if no page could be fetched at all, so the run did not produce any meaningful result.
+func (rr *RunReport) Failed() bool {
+	summary := rr.Summary()
+	return summary.HttpFailures > 0 && summary.PagesFetched == 0
+}
+
+func (rr *RunReport) update(err error, update func(*RunSummary)) {
+	if rr == nil {
+		return
+	}
+	rr.mutex.Lock()
+	defer rr.mutex.Unlock()
+	update(&rr.summary)
+	if err != nil && len(rr.errors) < maxReportedErrors {
+		rr.errors = append(rr.errors, err)
+	}
+}
+
+// recordVisit records the result of fetching a single page.
+func (rr *RunReport) recordVisit(err error) {
+	rr.update(err, func(summary *RunSummary) {
+		if err != nil {
+			summary.HttpFailures++
+		} else {
+			summary.PagesFetched++
+		}
+	})
+}
+
+func (rr *RunReport) recordParseFailure() {
+	rr.update(nil, func(summary *RunSummary) { summary.ParseFailures++ })
+}
+
+// recordContent records the result of fetching the content of an entry. Pages that could not be fetched count as
+// HTTP failures, everything else as a content failure.
+func (rr *RunReport) recordContent(err error) {
+	var requestError *RequestError
+	if errors.As(err, &requestError) {
+		rr.recordVisit(err)
+		return
+	}
+	rr.recordVisit(nil)
+	if err != nil {
+		rr.update(nil, func(summary *RunSummary) { summary.ContentFailures++ })
+	}
+}
+
+// visitAndWait visits the given link and waits until the collector is done. It returns a RequestError if the page
+// could not be fetched.
+func visitAndWait(c *colly.Collector, link string) error {
+	var mutex sync.Mutex
+	var requestErr error
+	setErr := func(r *colly.Response, err error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if requestErr == nil {
+			requestErr = newRequestError(link, r, err)
+		}
+	}
+	c.OnError(setErr)
+	c.OnResponse(func(r *colly.Response) {
+		// Maintenance pages may be served with a regular status code
+		if unavailable := detectUnavailable(r); unavailable != nil {
+			setErr(r, unavailable)
+		}
+	})
+
+	err := c.Visit(link)
+	c.Wait()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if requestErr == nil && err != nil {
+		requestErr = newRequestError(link, nil, err)
+	}
+	return requestErr
+}
+
+func newRequestError(link string, r *colly.Response, err error) *RequestError {
+	requestError := RequestError{Url: link, Err: err}
+	if r == nil {
+		return &requestError
+	}
+	requestError.StatusCode = r.StatusCode
+	if unavailable := detectUnavailable(r); unavailable != nil {
+		// Keep the typed error accessible, so callers can pause polling
+		requestError.Err = unavailable
+	}
+	return &requestError
+}
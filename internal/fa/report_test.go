@@ -0,0 +1,53 @@
+package fa
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gocolly/colly/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVisitAndWait(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+			_, _ = w.Write([]byte(`<html><body><div id="site-content"></div></body></html>`))
+		case "/maintenance":
+			_, _ = w.Write([]byte(`<html><head><title>Site Maintenance</title></head><body></body></html>`))
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	report := newRunReport()
+	for _, path := range []string{"/ok", "/maintenance", "/broken"} {
+		report.recordVisit(visitAndWait(colly.NewCollector(colly.Async(true)), server.URL+path))
+	}
+
+	summary := report.Summary()
+	assert.Equal(t, 1, summary.PagesFetched)
+	assert.Equal(t, 2, summary.HttpFailures)
+	assert.False(t, report.Failed())
+
+	err := report.Err()
+	require.Error(t, err)
+	var requestError *RequestError
+	require.True(t, errors.As(err, &requestError))
+	unavailable, ok := AsUnavailable(err)
+	require.True(t, ok)
+	assert.Equal(t, UnavailableMaintenance, unavailable.Kind)
+}
+
+func TestRunReport_RecordContent(t *testing.T) {
+	report := newRunReport()
+	report.recordContent(nil)
+	report.recordContent(errors.New("no content found"))
+	report.recordContent(&RequestError{Url: "https://example.com", Err: errors.New("timeout")})
+
+	assert.Equal(t, RunSummary{PagesFetched: 2, HttpFailures: 1, ContentFailures: 1}, report.Summary())
+}
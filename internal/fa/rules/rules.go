@@ -0,0 +1,266 @@
+// Package rules implements user-defined include and exclude rules for entries.
+package rules
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type (
+	// Action defines whether matching entries are the only ones sent or never sent.
+	Action uint8
+	// Field is the part of an entry a rule is evaluated on.
+	Field uint8
+
+	// Subject holds the parts of an entry rules can be evaluated on.
+	Subject struct {
+		Title       string
+		Description string
+		Tags        []string
+		Author      string
+		Rating      string
+		Type        string
+		Category    string
+		Species     string
+		Gender      string
+	}
+
+	// Rule matches entries whose field contains a keyword or matches a regular expression.
+	Rule struct {
+		Action  Action
+		Field   Field
+		Pattern string
+		Regex   bool
+		regex   *regexp.Regexp
+	}
+
+	// RuleSet evaluates multiple rules at once. An entry passes if it matches none of the exclude rules and, if there
+	// are any include rules, at least one of them. A nil RuleSet lets all entries pass.
+	RuleSet struct {
+		include []*Rule
+		exclude []*Rule
+	}
+)
+
+const (
+	ActionInclude Action = iota
+	ActionExclude
+)
+
+const (
+	// FieldAny matches title, description, tags and author
+	FieldAny Field = iota
+	FieldTitle
+	FieldDescription
+	FieldTags
+	FieldAuthor
+	FieldRating
+	FieldType
+	FieldCategory
+	FieldSpecies
+	FieldGender
+)
+
+func Actions() []Action {
+	return []Action{ActionInclude, ActionExclude}
+}
+
+func Fields() []Field {
+	return []Field{
+		FieldAny, FieldTitle, FieldDescription, FieldTags, FieldAuthor, FieldRating, FieldType, FieldCategory,
+		FieldSpecies, FieldGender,
+	}
+}
+
+func (a Action) String() string {
+	switch a {
+	case ActionInclude:
+		return "include"
+	case ActionExclude:
+		return "exclude"
+	}
+	panic(fmt.Sprintf("unreachable: unknown rule action %d", a))
+}
+
+func (f Field) String() string {
+	switch f {
+	case FieldAny:
+		return "any"
+	case FieldTitle:
+		return "title"
+	case FieldDescription:
+		return "description"
+	case FieldTags:
+		return "tags"
+	case FieldAuthor:
+		return "author"
+	case FieldRating:
+		return "rating"
+	case FieldType:
+		return "type"
+	case FieldCategory:
+		return "category"
+	case FieldSpecies:
+		return "species"
+	case FieldGender:
+		return "gender"
+	}
+	panic(fmt.Sprintf("unreachable: unknown rule field %d", f))
+}
+
+func ParseAction(s string) (Action, error) {
+	for _, action := range Actions() {
+		if strings.EqualFold(s, action.String()) {
+			return action, nil
+		}
+	}
+	return ActionInclude, fmt.Errorf("unknown rule action '%s'", s)
+}
+
+func ParseField(s string) (Field, error) {
+	for _, field := range Fields() {
+		if strings.EqualFold(s, field.String()) {
+			return field, nil
+		}
+	}
+	return FieldAny, fmt.Errorf("unknown rule field '%s'", s)
+}
+
+// Compile creates a rule. Keywords are matched case-insensitively as a substring of title and description, and as a
+// whole value for all other fields. Regular expressions are always case-insensitive.
+func Compile(action Action, field Field, pattern string, regex bool) (*Rule, error) {
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" {
+		return nil, fmt.Errorf("empty rule pattern")
+	}
+	rule := Rule{Action: action, Field: field, Pattern: pattern, Regex: regex}
+	if regex {
+		compiled, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression: %w", err)
+		}
+		rule.regex = compiled
+	}
+	return &rule, nil
+}
+
+// Parse creates a rule from a pattern as entered by a user. Patterns enclosed in slashes are regular expressions.
+func Parse(action Action, field Field, pattern string) (*Rule, error) {
+	pattern = strings.TrimSpace(pattern)
+	if len(pattern) > 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		return Compile(action, field, pattern[1:len(pattern)-1], true)
+	}
+	return Compile(action, field, pattern, false)
+}
+
+// String returns the rule in the form it can be entered in.
+func (r *Rule) String() string {
+	pattern := r.Pattern
+	if r.Regex {
+		pattern = "/" + pattern + "/"
+	}
+	return fmt.Sprintf("%s %s %s", r.Action, r.Field, pattern)
+}
+
+// Matches returns true if the rule matches the subject.
+func (r *Rule) Matches(s *Subject) bool {
+	switch r.Field {
+	case FieldAny:
+		return r.matchesText(s.Title) || r.matchesText(s.Description) ||
+			r.matchesAnyValue(s.Tags) || r.matchesValue(s.Author)
+	case FieldTitle:
+		return r.matchesText(s.Title)
+	case FieldDescription:
+		return r.matchesText(s.Description)
+	case FieldTags:
+		return r.matchesAnyValue(s.Tags)
+	case FieldAuthor:
+		return r.matchesValue(s.Author)
+	case FieldRating:
+		return r.matchesValue(s.Rating)
+	case FieldType:
+		return r.matchesValue(s.Type)
+	case FieldCategory:
+		return r.matchesValue(s.Category)
+	case FieldSpecies:
+		return r.matchesValue(s.Species)
+	case FieldGender:
+		return r.matchesValue(s.Gender)
+	}
+	return false
+}
+
+func (r *Rule) matchesText(text string) bool {
+	if r.regex != nil {
+		return r.regex.MatchString(text)
+	}
+	return strings.Contains(strings.ToLower(text), strings.ToLower(r.Pattern))
+}
+
+func (r *Rule) matchesValue(value string) bool {
+	if r.regex != nil {
+		return r.regex.MatchString(value)
+	}
+	return strings.EqualFold(strings.TrimSpace(value), r.Pattern)
+}
+
+func (r *Rule) matchesAnyValue(values []string) bool {
+	for _, value := range values {
+		if r.matchesValue(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func NewRuleSet(rules ...*Rule) *RuleSet {
+	rs := RuleSet{}
+	for _, rule := range rules {
+		switch rule.Action {
+		case ActionInclude:
+			rs.include = append(rs.include, rule)
+		case ActionExclude:
+			rs.exclude = append(rs.exclude, rule)
+		}
+	}
+	return &rs
+}
+
+// Empty returns true if the rule set has no rules.
+func (rs *RuleSet) Empty() bool {
+	return rs == nil || len(rs.include)+len(rs.exclude) == 0
+}
+
+// Excludes returns true if the subject matches any of the exclude rules. Unlike Passes, it can be used on incomplete
+// subjects, as more details can only make an exclude rule match, never stop it from matching.
+func (rs *RuleSet) Excludes(s *Subject) bool {
+	if rs.Empty() {
+		return false
+	}
+	for _, rule := range rs.exclude {
+		if rule.Matches(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Passes returns true if the subject should be sent according to the rules.
+func (rs *RuleSet) Passes(s *Subject) bool {
+	if rs.Empty() {
+		return true
+	}
+	if rs.Excludes(s) {
+		return false
+	}
+	if len(rs.include) == 0 {
+		return true
+	}
+	for _, rule := range rs.include {
+		if rule.Matches(s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testSubject = Subject{
+	Title:       "YCH auction - Summer edition",
+	Description: "Commissions are open! Slots are limited.",
+	Tags:        []string{"ych", "fox", "beach"},
+	Author:      "someartist",
+	Rating:      "General",
+	Type:        "Image",
+	Category:    "Artwork (Digital)",
+	Species:     "Red Fox",
+	Gender:      "Male",
+}
+
+func mustParse(t *testing.T, action Action, field Field, pattern string) *Rule {
+	rule, err := Parse(action, field, pattern)
+	require.NoError(t, err)
+	return rule
+}
+
+func TestRuleMatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		field    Field
+		pattern  string
+		expected bool
+	}{
+		{"title keyword", FieldTitle, "ych", true},
+		{"title keyword missing", FieldTitle, "adoptable", false},
+		{"description keyword", FieldDescription, "commissions are open", true},
+		{"description regex", FieldDescription, `/commissions? (are )?open/`, true},
+		{"tag", FieldTags, "Fox", true},
+		{"tag is not a substring match", FieldTags, "fo", false},
+		{"tag regex", FieldTags, "/^bea/", true},
+		{"author", FieldAuthor, "SomeArtist", true},
+		{"rating", FieldRating, "general", true},
+		{"rating mismatch", FieldRating, "adult", false},
+		{"type", FieldType, "image", true},
+		{"category", FieldCategory, "artwork (digital)", true},
+		{"species", FieldSpecies, "red fox", true},
+		{"species is not a substring match", FieldSpecies, "fox", false},
+		{"species regex", FieldSpecies, "/fox/", true},
+		{"gender", FieldGender, "male", true},
+		{"gender mismatch", FieldGender, "female", false},
+		{"any matches tags", FieldAny, "beach", true},
+		{"any matches description", FieldAny, "slots", true},
+		{"any without match", FieldAny, "wolf", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule := mustParse(t, ActionInclude, test.field, test.pattern)
+			assert.Equal(t, test.expected, rule.Matches(&testSubject))
+		})
+	}
+}
+
+func TestParse_InvalidRegex(t *testing.T) {
+	_, err := Parse(ActionInclude, FieldTitle, "/[a-/")
+	assert.Error(t, err)
+
+	_, err = Parse(ActionInclude, FieldTitle, "  ")
+	assert.Error(t, err)
+}
+
+func TestRuleString(t *testing.T) {
+	assert.Equal(t, "exclude tags /^ve/", mustParse(t, ActionExclude, FieldTags, "/^ve/").String())
+	assert.Equal(t, "include title ych", mustParse(t, ActionInclude, FieldTitle, "ych").String())
+}
+
+func TestRuleSetPasses(t *testing.T) {
+	includeYch := mustParse(t, ActionInclude, FieldTitle, "ych")
+	includeAdopt := mustParse(t, ActionInclude, FieldTitle, "adoptable")
+	excludeFox := mustParse(t, ActionExclude, FieldTags, "fox")
+	excludeWolf := mustParse(t, ActionExclude, FieldTags, "wolf")
+
+	tests := []struct {
+		name     string
+		rules    *RuleSet
+		expected bool
+	}{
+		{"nil rule set", nil, true},
+		{"empty rule set", NewRuleSet(), true},
+		{"matching include", NewRuleSet(includeYch), true},
+		{"one of the includes matches", NewRuleSet(includeAdopt, includeYch), true},
+		{"no include matches", NewRuleSet(includeAdopt), false},
+		{"matching exclude", NewRuleSet(excludeFox), false},
+		{"exclude wins over include", NewRuleSet(includeYch, excludeFox), false},
+		{"exclude without match", NewRuleSet(excludeWolf), true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, test.rules.Passes(&testSubject))
+		})
+	}
+}
+
+func TestRuleSetExcludes(t *testing.T) {
+	includeAdopt := mustParse(t, ActionInclude, FieldTitle, "adoptable")
+	excludeFox := mustParse(t, ActionExclude, FieldTags, "fox")
+	excludeWolf := mustParse(t, ActionExclude, FieldTags, "wolf")
+
+	tests := []struct {
+		name     string
+		rules    *RuleSet
+		expected bool
+	}{
+		{"nil rule set", nil, false},
+		{"non-matching include is ignored", NewRuleSet(includeAdopt), false},
+		{"matching exclude", NewRuleSet(includeAdopt, excludeFox), true},
+		{"exclude without match", NewRuleSet(excludeWolf), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, test.rules.Excludes(&testSubject))
+		})
+	}
+}
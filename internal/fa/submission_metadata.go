@@ -0,0 +1,185 @@
+package fa
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/fanonwue/goutils/logging"
+)
+
+type (
+	// SubmissionMetadata holds the details FA shows in the sidebar of a submission's view page.
+	SubmissionMetadata struct {
+		Category string
+		// Theme is the second part of the category, e.g. "All" in "Artwork (Digital) / All"
+		Theme    string
+		Species  string
+		Gender   string
+		Keywords []string
+		Views    uint
+		Comments uint
+		// Favorites is the amount of users that faved the submission
+		Favorites uint
+		Width     uint
+		Height    uint
+		// FileSize is the size of the submission file in bytes, zero if FA does not show it
+		FileSize uint64
+		Folders  []SubmissionFolder
+	}
+
+	SubmissionFolder struct {
+		Name string
+		Url  *url.URL
+	}
+)
+
+const submissionSidebarSelector = ".submission-sidebar"
+
+var (
+	// Matches resolutions like "1280 x 960"
+	resolutionRegex = regexp.MustCompile(`(\d+)\s*x\s*(\d+)`)
+	// Matches file sizes like "1.2 MB" or "345 KB"
+	fileSizeRegex = regexp.MustCompile(`(?i)^([\d.,]+)\s*([KMG]?)i?B$`)
+)
+
+// InFolder returns true if the submission is part of at least one gallery folder.
+func (sm *SubmissionMetadata) InFolder() bool {
+	return len(sm.Folders) > 0
+}
+
+// Resolution returns the resolution in the form FA shows it, or an empty string if it is unknown.
+func (sm *SubmissionMetadata) Resolution() string {
+	if sm.Width == 0 || sm.Height == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%dx%d", sm.Width, sm.Height)
+}
+
+// HumanFileSize returns the file size in a human-readable form, or an empty string if it is unknown.
+func (sm *SubmissionMetadata) HumanFileSize() string {
+	if sm.FileSize == 0 {
+		return ""
+	}
+	units := []string{"B", "KB", "MB", "GB"}
+	size := float64(sm.FileSize)
+	unit := 0
+	for size >= 1024 && unit < len(units)-1 {
+		size /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%d B", sm.FileSize)
+	}
+	return fmt.Sprintf("%.1f %s", size, units[unit])
+}
+
+// parseSubmissionMetadata parses the sidebar of a submission's view page. It returns nil if the sidebar could not be
+// found.
+func parseSubmissionMetadata(page *goquery.Selection) *SubmissionMetadata {
+	sidebar := page.Find(submissionSidebarSelector).First()
+	if sidebar.Length() == 0 {
+		return nil
+	}
+
+	metadata := SubmissionMetadata{}
+
+	sidebar.Find("section.info > div").Each(func(i int, row *goquery.Selection) {
+		label := strings.ToLower(trimHtmlText(row.Find(".highlight").First().Text()))
+		value := row.Clone()
+		value.Find(".highlight").Remove()
+		text := trimHtmlText(value.Text())
+
+		switch label {
+		case "category":
+			metadata.Category = trimHtmlText(row.Find(".category-name").Text())
+			metadata.Theme = trimHtmlText(row.Find(".type-name").Text())
+			if metadata.Category == "" {
+				metadata.Category = text
+			}
+		case "species":
+			metadata.Species = text
+		case "gender":
+			metadata.Gender = text
+		case "size", "resolution":
+			metadata.Width, metadata.Height = parseResolution(text)
+		case "file size":
+			metadata.FileSize = parseFileSize(text)
+		}
+	})
+
+	seen := make(map[string]struct{})
+	sidebar.Find(".tags-row a").Each(func(i int, tag *goquery.Selection) {
+		keyword := trimHtmlText(tag.Text())
+		if _, found := seen[keyword]; found || keyword == "" {
+			return
+		}
+		seen[keyword] = struct{}{}
+		metadata.Keywords = append(metadata.Keywords, keyword)
+	})
+
+	stats := sidebar.Find(".stats-container")
+	metadata.Views = parseStatCounter(stats, ".views")
+	metadata.Comments = parseStatCounter(stats, ".comments")
+	metadata.Favorites = parseStatCounter(stats, ".favorites")
+
+	sidebar.Find(".folder-list-container a").Each(func(i int, link *goquery.Selection) {
+		folder := SubmissionFolder{Name: trimHtmlText(link.Text())}
+		folderUrl, err := FurAffinityUrl().Parse(link.AttrOr("href", ""))
+		if err == nil {
+			folder.Url = folderUrl
+		}
+		metadata.Folders = append(metadata.Folders, folder)
+	})
+
+	return &metadata
+}
+
+func parseStatCounter(stats *goquery.Selection, selector string) uint {
+	raw := trimHtmlText(stats.Find(selector + " .font-large").First().Text())
+	if raw == "" {
+		return 0
+	}
+	count, err := parseCounterValue(raw)
+	if err != nil {
+		logging.Warnf("Error parsing submission stat '%s': %s", selector, err)
+		return 0
+	}
+	return count
+}
+
+func parseResolution(s string) (uint, uint) {
+	matches := resolutionRegex.FindStringSubmatch(s)
+	if len(matches) < 3 {
+		return 0, 0
+	}
+	width, errWidth := strconv.ParseUint(matches[1], 10, 32)
+	height, errHeight := strconv.ParseUint(matches[2], 10, 32)
+	if errWidth != nil || errHeight != nil {
+		return 0, 0
+	}
+	return uint(width), uint(height)
+}
+
+func parseFileSize(s string) uint64 {
+	matches := fileSizeRegex.FindStringSubmatch(strings.TrimSpace(s))
+	if len(matches) < 3 {
+		return 0
+	}
+	value, err := strconv.ParseFloat(strings.ReplaceAll(matches[1], ",", ""), 64)
+	if err != nil {
+		return 0
+	}
+	switch strings.ToUpper(matches[2]) {
+	case "K":
+		value *= 1 << 10
+	case "M":
+		value *= 1 << 20
+	case "G":
+		value *= 1 << 30
+	}
+	return uint64(value)
+}
@@ -0,0 +1,86 @@
+package fa
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSubmissionSidebar = `
+<div class="submission-sidebar">
+	<section class="stats-container text">
+		<div class="views"><span class="font-large">1,024</span> <span>Views</span></div>
+		<div class="comments"><span class="font-large">12</span> <span>Comments</span></div>
+		<div class="favorites"><span class="font-large">345</span> <span>Favorites</span></div>
+	</section>
+	<section class="info text">
+		<div><strong class="highlight">Category</strong> <span class="category-name">Artwork (Digital)</span> / <span class="type-name">All</span></div>
+		<div><strong class="highlight">Species</strong> <span>Red Fox</span></div>
+		<div><strong class="highlight">Gender</strong> <span>Male</span></div>
+		<div><strong class="highlight">Size</strong> <span>1280 x 960</span></div>
+		<div><strong class="highlight">File Size</strong> <span>1.5 MB</span></div>
+	</section>
+	<section class="tags-section">
+		<span class="tags-row"><span class="tags"><a href="/search/@keywords fox">fox</a></span></span>
+		<span class="tags-row"><span class="tags"><a href="/search/@keywords forest">forest</a></span></span>
+		<span class="tags-row"><span class="tags"><a href="/search/@keywords fox">fox</a></span></span>
+	</section>
+	<section class="buttons">
+		<div class="download"><a href="//d.furaffinity.net/art/someartist/1700000000/1700000000.someartist_story.txt">Download</a></div>
+	</section>
+	<section class="folder-list-container">
+		<div><a href="/gallery/someartist/folder/123/Commissions/">Commissions</a></div>
+	</section>
+</div>`
+
+func TestParseSubmissionMetadata(t *testing.T) {
+	metadata := parseSubmissionMetadata(testDocument(t, testSubmissionSidebar).Selection)
+	require.NotNil(t, metadata)
+
+	assert.Equal(t, "Artwork (Digital)", metadata.Category)
+	assert.Equal(t, "All", metadata.Theme)
+	assert.Equal(t, "Red Fox", metadata.Species)
+	assert.Equal(t, "Male", metadata.Gender)
+	assert.Equal(t, []string{"fox", "forest"}, metadata.Keywords)
+	assert.Equal(t, uint(1024), metadata.Views)
+	assert.Equal(t, uint(12), metadata.Comments)
+	assert.Equal(t, uint(345), metadata.Favorites)
+	assert.Equal(t, "1280x960", metadata.Resolution())
+	assert.Equal(t, uint64(1.5*(1<<20)), metadata.FileSize)
+	assert.Equal(t, "1.5 MB", metadata.HumanFileSize())
+
+	require.True(t, metadata.InFolder())
+	assert.Equal(t, "Commissions", metadata.Folders[0].Name)
+	assert.Equal(t, "/gallery/someartist/folder/123/Commissions/", metadata.Folders[0].Url.Path)
+}
+
+func TestSubmissionDownload(t *testing.T) {
+	download := submissionDownload(testDocument(t, testSubmissionSidebar).Selection)
+	require.NotNil(t, download)
+	assert.Equal(t, "https://d.furaffinity.net/art/someartist/1700000000/1700000000.someartist_story.txt", download.String())
+
+	assert.Nil(t, submissionDownload(testDocument(t, `<div class="submission-sidebar"></div>`).Selection))
+}
+
+func TestParseSubmissionMetadata_MissingSidebar(t *testing.T) {
+	assert.Nil(t, parseSubmissionMetadata(testDocument(t, `<div class="submission-content"></div>`).Selection))
+}
+
+func TestParseFileSize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected uint64
+	}{
+		{"512 B", 512},
+		{"345 KB", 345 << 10},
+		{"2 MB", 2 << 20},
+		{"1,024 KB", 1 << 20},
+		{"unknown", 0},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			assert.Equal(t, test.expected, parseFileSize(test.input))
+		})
+	}
+}
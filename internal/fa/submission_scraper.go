@@ -1,11 +1,14 @@
 package fa
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
 	"net/url"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -17,6 +20,7 @@ import (
 	"github.com/fanonwue/goutils/logging"
 	"github.com/gocolly/colly/v2"
 	"github.com/senexdrake/furaffinity-notifier/internal/fa/entries"
+	"github.com/senexdrake/furaffinity-notifier/internal/fa/rules"
 	"github.com/senexdrake/furaffinity-notifier/internal/fa/tools"
 	"github.com/senexdrake/furaffinity-notifier/internal/util"
 )
@@ -26,6 +30,7 @@ type (
 		date           time.Time
 		submissionData SubmissionDataMap
 		blockedTags    dsext.Set[string]
+		health         *pageHealth
 	}
 	SubmissionEntry struct {
 		id             uint
@@ -45,8 +50,10 @@ type (
 		descriptionText string
 		descriptionHtml string
 		full            *url.URL
+		download        *url.URL
 		thumbnail       *tools.ThumbnailUrl
 		date            time.Time
+		metadata        *SubmissionMetadata
 	}
 
 	SubmissionData struct {
@@ -69,6 +76,10 @@ func (st SubmissionType) String() string {
 		return "Image"
 	case SubmissionTypeText:
 		return "Text"
+	case SubmissionTypeAudio:
+		return "Audio"
+	case SubmissionTypeFlash:
+		return "Flash"
 	}
 	panic("unreachable")
 }
@@ -77,6 +88,8 @@ const (
 	SubmissionTypeUnknown SubmissionType = iota
 	SubmissionTypeImage
 	SubmissionTypeText
+	SubmissionTypeAudio
+	SubmissionTypeFlash
 )
 
 const submissionsPath = "/msg/submissions/new@72/"
@@ -146,6 +159,16 @@ func (se *SubmissionEntry) FullView() *url.URL {
 	return content.full
 }
 
+// Download returns the link to the submitted file, e.g. the story file of a text submission. Returns nil if no content
+// is available.
+func (se *SubmissionEntry) Download() *url.URL {
+	content := se.Content()
+	if content == nil {
+		return nil
+	}
+	return content.download
+}
+
 func (se *SubmissionEntry) SubmissionData() *SubmissionData {
 	return se.submissionData
 }
@@ -159,24 +182,69 @@ func (se *SubmissionEntry) SetContent(content *SubmissionContent) {
 	se.content = content
 }
 
+// Metadata returns the details from the submission's view page, or nil if no content is available.
+func (se *SubmissionEntry) Metadata() *SubmissionMetadata {
+	content := se.Content()
+	if content == nil {
+		return nil
+	}
+	return content.metadata
+}
+
+// Keywords returns the keywords from the submission's view page. If no content is available, the tags from the
+// submission listing are returned instead.
+func (se *SubmissionEntry) Keywords() []string {
+	metadata := se.Metadata()
+	if metadata != nil && len(metadata.Keywords) > 0 {
+		return metadata.Keywords
+	}
+	return slices.Sorted(maps.Keys(se.tags))
+}
+
+// RuleSubject returns the details of the submission that content rules are evaluated on.
+func (se *SubmissionEntry) RuleSubject() *rules.Subject {
+	author := ""
+	if se.From() != nil {
+		author = se.From().UserName
+	}
+	subject := rules.Subject{
+		Title:       se.Title(),
+		Description: se.Description(),
+		Tags:        se.Keywords(),
+		Author:      author,
+		Rating:      se.Rating().String(),
+		Type:        se.Type().String(),
+	}
+	// Category, species and gender are only known from the view page
+	if metadata := se.Metadata(); metadata != nil {
+		subject.Category = metadata.Category
+		subject.Species = metadata.Species
+		subject.Gender = metadata.Gender
+	}
+	return &subject
+}
+
 func (se *SubmissionEntry) Tags() dsext.Set[string]           { return se.tags }
 func (se *SubmissionEntry) BlockedReasons() dsext.Set[string] { return se.blockedReason }
 func (se *SubmissionEntry) IsBlocked() bool                   { return len(se.BlockedReasons()) > 0 }
 
-func (fc *FurAffinityCollector) submissionCollector() *colly.Collector {
-	c := fc.configuredCollector(true)
+func (fc *FurAffinityCollector) submissionCollector(ctx context.Context) *colly.Collector {
+	c := fc.configuredCollector(ctx, true)
 	return c
 }
 
-func (fc *FurAffinityCollector) GetSubmissionEntries() <-chan *SubmissionEntry {
-	c := fc.submissionCollector()
+// GetSubmissionEntries returns the submissions listed on the submission notifications page. The report is complete
+// once the channel has been closed.
+func (fc *FurAffinityCollector) GetSubmissionEntries(ctx context.Context) (<-chan *SubmissionEntry, *RunReport) {
+	c := fc.submissionCollector(ctx)
 
 	channel := make(chan *SubmissionEntry, fc.channelBufferSize())
+	report := newRunReport()
+	health := fc.watchPageHealth(c, PageKindSubmissions, report)
 
 	c.OnHTML("body", func(bodyElement *colly.HTMLElement) {
 
-		blockedTagsRaw := bodyElement.DOM.AttrOr("data-tag-blocklist", "")
-		blockedTags := tools.TagListToSet(blockedTagsRaw)
+		blockedTags := fc.blockedTags(bodyElement.DOM.AttrOr("data-tag-blocklist", ""))
 
 		rawSubmissionData := bodyElement.DOM.Find("#js-submissionData").First().Text()
 		submissionData := parseSubmissionData(rawSubmissionData)
@@ -196,6 +264,7 @@ func (fc *FurAffinityCollector) GetSubmissionEntries() <-chan *SubmissionEntry {
 				date:           date,
 				submissionData: submissionData,
 				blockedTags:    blockedTags,
+				health:         health,
 			}
 
 			fc.submissionHandlerWrapper(
@@ -211,16 +280,19 @@ func (fc *FurAffinityCollector) GetSubmissionEntries() <-chan *SubmissionEntry {
 
 	go func() {
 		defer close(channel)
-		c.Visit(link.String())
-		c.Wait()
+		err := visitAndWait(c, link.String())
+		report.recordVisit(err)
+		if err != nil {
+			logging.Errorf("Error while scraping submissions: %v", err)
+		}
 	}()
 
 	if fc.IterateSubmissionsBackwards {
 		// The expected submission count is 72, so we can preallocate that amount
-		return util.BackwardsChannelWithCapacity(channel, 72)
+		return util.BackwardsChannelWithCapacity(channel, 72), report
 	}
 
-	return channel
+	return channel, report
 }
 
 func (fc *FurAffinityCollector) submissionHandlerWrapper(
@@ -237,6 +309,7 @@ func (fc *FurAffinityCollector) submissionHandlerWrapper(
 		defer wg.Done()
 
 		entry, err := fc.parseSubmission(el, context)
+		context.health.record(err == nil && entry != nil)
 		if err != nil || entry == nil {
 			logging.Warnf("Error parsing submission: %s", err)
 			return
@@ -245,11 +318,20 @@ func (fc *FurAffinityCollector) submissionHandlerWrapper(
 		if !fc.IsWhitelisted(entries.EntryTypeSubmission, entry.From().UserName) {
 			return
 		}
+		if !fc.IsRatingAllowed(entries.EntryTypeSubmission, entry.Rating()) {
+			return
+		}
 
 		data, found := context.submissionData[entry.ID()]
 		if found {
 			entry.submissionData = data
 		}
+		if !fc.passesListingRules(entry) {
+			if fc.isSubmissionNew(entry.ID()) {
+				fc.rejectEntry(entry)
+			}
+			return
+		}
 		channel <- entry
 	})
 
@@ -257,31 +339,57 @@ func (fc *FurAffinityCollector) submissionHandlerWrapper(
 	wg.Wait()
 }
 
-func (fc *FurAffinityCollector) GetNewSubmissionEntries() <-chan *SubmissionEntry {
-	filtered := make(chan *SubmissionEntry, fc.channelBufferSize())
-	all := fc.GetSubmissionEntries()
+// passesListingRules evaluates the rules on the details from the listing, so unwanted submissions don't cost a request
+// for their content. Only exclude rules are evaluated, as include rules might match details that are only known once
+// the content has been fetched, like the full description or the keywords.
+func (fc *FurAffinityCollector) passesListingRules(entry *SubmissionEntry) bool {
+	return !fc.Rules.Excludes(entry.RuleSubject())
+}
+
+// GetNewSubmissionEntries returns the new submissions without their content. The rules are evaluated on the details
+// from the listing, as no others will be available.
+func (fc *FurAffinityCollector) GetNewSubmissionEntries(ctx context.Context) (<-chan *SubmissionEntry, *RunReport) {
+	return fc.newSubmissionEntries(ctx, true)
+}
 
+func (fc *FurAffinityCollector) newSubmissionEntries(ctx context.Context, applyRules bool) (<-chan *SubmissionEntry, *RunReport) {
+	all, report := fc.GetSubmissionEntries(ctx)
+	return fc.filterNewSubmissions(all, applyRules), report
+}
+
+func (fc *FurAffinityCollector) filterNewSubmissions(all <-chan *SubmissionEntry, applyRules bool) <-chan *SubmissionEntry {
+	filtered := make(chan *SubmissionEntry, fc.channelBufferSize())
 	go func() {
 		defer close(filtered)
 		for submission := range all {
-			if fc.isSubmissionNew(submission.ID()) {
-				filtered <- submission
+			if !fc.isSubmissionNew(submission.ID()) {
+				continue
+			}
+			if applyRules && !fc.Rules.Passes(submission.RuleSubject()) {
+				fc.rejectEntry(submission)
+				continue
 			}
+			filtered <- submission
 		}
 	}()
-
 	return filtered
 }
 
-func (fc *FurAffinityCollector) GetNewSubmissionEntriesWithContent() <-chan *SubmissionEntry {
-	return fc.submissionsWithContent(fc.GetNewSubmissionEntries())
+func (fc *FurAffinityCollector) GetNewSubmissionEntriesWithContent(ctx context.Context) (<-chan *SubmissionEntry, *RunReport) {
+	entryChannel, report := fc.newSubmissionEntries(ctx, false)
+	return fc.submissionsWithContent(ctx, entryChannel, report)
 }
 
-func (fc *FurAffinityCollector) GetSubmissionEntriesWithContent() <-chan *SubmissionEntry {
-	return fc.submissionsWithContent(fc.GetSubmissionEntries())
+func (fc *FurAffinityCollector) GetSubmissionEntriesWithContent(ctx context.Context) (<-chan *SubmissionEntry, *RunReport) {
+	entryChannel, report := fc.GetSubmissionEntries(ctx)
+	return fc.submissionsWithContent(ctx, entryChannel, report)
 }
 
-func (fc *FurAffinityCollector) submissionsWithContent(entryChannel <-chan *SubmissionEntry) <-chan *SubmissionEntry {
+func (fc *FurAffinityCollector) submissionsWithContent(
+	ctx context.Context,
+	entryChannel <-chan *SubmissionEntry,
+	report *RunReport,
+) (<-chan *SubmissionEntry, *RunReport) {
 	channel := make(chan *SubmissionEntry, fc.channelBufferSize())
 	go func() {
 		defer close(channel)
@@ -302,9 +410,13 @@ func (fc *FurAffinityCollector) submissionsWithContent(entryChannel <-chan *Subm
 					wg.Done()
 				}()
 
-				content := fc.GetSubmissionContent(entry)
-				if content == nil {
-					logging.Warnf("Failed to retrieve content for submission %d", entry.ID())
+				content, err := fc.GetSubmissionContent(ctx, entry)
+				if ctx.Err() != nil {
+					return
+				}
+				report.recordContent(err)
+				if err != nil {
+					logging.Warnf("Failed to retrieve content for submission %d: %s", entry.ID(), err)
 					return
 				}
 				// The content might have more detailed date information, so we should check the submission date again
@@ -313,6 +425,11 @@ func (fc *FurAffinityCollector) submissionsWithContent(entryChannel <-chan *Subm
 				}
 
 				entry.SetContent(content)
+				// All rules are evaluated now that the full description and keywords are known
+				if !fc.Rules.Passes(entry.RuleSubject()) {
+					fc.rejectEntry(entry)
+					return
+				}
 				channel <- entry
 			}()
 		}
@@ -320,19 +437,25 @@ func (fc *FurAffinityCollector) submissionsWithContent(entryChannel <-chan *Subm
 		wg.Wait()
 	}()
 
-	return channel
+	return channel, report
 }
 
-func (fc *FurAffinityCollector) GetSubmissionContent(entry *SubmissionEntry) *SubmissionContent {
-	if entry.Type() != SubmissionTypeImage {
-		return nil
-	}
-	c := fc.otherCollector()
+func (fc *FurAffinityCollector) GetSubmissionContent(ctx context.Context, entry *SubmissionEntry) (*SubmissionContent, error) {
+	c := fc.otherCollector(ctx)
 
 	content := SubmissionContent{id: entry.ID(), thumbnail: entry.Thumbnail()}
 
 	valid := false
 
+	c.OnHTML("body", func(e *colly.HTMLElement) {
+		// The sidebar is not part of the submission content container
+		content.metadata = parseSubmissionMetadata(e.DOM)
+		content.download = submissionDownload(e.DOM)
+		if content.metadata == nil {
+			logging.Debugf("No metadata found for submission %d", entry.ID())
+		}
+	})
+
 	c.OnHTML(".submission-content", func(e *colly.HTMLElement) {
 		if valid {
 			// content has already been found
@@ -340,7 +463,7 @@ func (fc *FurAffinityCollector) GetSubmissionContent(entry *SubmissionEntry) *Su
 		}
 
 		content.full = submissionFullView(entry.Type(), e)
-		if content.full == nil {
+		if content.full == nil && entry.Type() == SubmissionTypeImage {
 			logging.Warnf("No full view link found for submission %d", entry.ID())
 			valid = false
 			return
@@ -372,14 +495,15 @@ func (fc *FurAffinityCollector) GetSubmissionContent(entry *SubmissionEntry) *Su
 		valid = true
 	})
 
-	c.Visit(entry.Link().String())
-	c.Wait()
-
+	err := visitAndWait(c, entry.Link().String())
+	if err != nil {
+		return nil, err
+	}
 	if !valid {
-		return nil
+		return nil, fmt.Errorf("no valid content found for submission %d", entry.ID())
 	}
 
-	return &content
+	return &content, nil
 }
 
 func (fc *FurAffinityCollector) isSubmissionNew(id uint) bool {
@@ -396,6 +520,10 @@ func (fc *FurAffinityCollector) parseSubmission(entryElement *colly.HTMLElement,
 		entry.submissionType = SubmissionTypeImage
 	} else if entryElement.DOM.HasClass("t-text") {
 		entry.submissionType = SubmissionTypeText
+	} else if entryElement.DOM.HasClass("t-audio") || entryElement.DOM.HasClass("t-music") {
+		entry.submissionType = SubmissionTypeAudio
+	} else if entryElement.DOM.HasClass("t-flash") {
+		entry.submissionType = SubmissionTypeFlash
 	}
 
 	if entryElement.DOM.HasClass("r-general") {
@@ -443,7 +571,7 @@ func (fc *FurAffinityCollector) parseSubmission(entryElement *colly.HTMLElement,
 	rawTags := imgElement.AttrOr("data-tags", "")
 	entry.tags = tools.TagListToSet(rawTags)
 
-	if fc.RespectBlockedTags {
+	if len(context.blockedTags) > 0 {
 		blockedReason := entry.tags.Intersect(context.blockedTags)
 		if len(blockedReason) > 0 {
 			entry.blockedReason = blockedReason
@@ -453,6 +581,17 @@ func (fc *FurAffinityCollector) parseSubmission(entryElement *colly.HTMLElement,
 	return &entry, nil
 }
 
+// blockedTags merges the tag blocklist of the FA account with the extra blocked tags of the user.
+func (fc *FurAffinityCollector) blockedTags(rawBlocklist string) dsext.Set[string] {
+	blockedTags := dsext.NewSet[string]()
+	if fc.RespectBlockedTags {
+		blockedTags.AddAllSet(tools.TagListToSet(rawBlocklist))
+		blockedTags.Remove("")
+	}
+	blockedTags.AddAllSet(fc.ExtraBlockedTags)
+	return blockedTags
+}
+
 func submissionSectionDate(el *colly.HTMLElement) (time.Time, error) {
 	timeFromAttr, err := goutils.EpochStringToTime(el.Attr("data-date"))
 	if err != nil {
@@ -497,7 +636,7 @@ func submissionThumbnail(imgElement *goquery.Selection) *tools.ThumbnailUrl {
 
 func submissionFullView(submissionType SubmissionType, el *colly.HTMLElement) *url.URL {
 	if submissionType != SubmissionTypeImage {
-		// Only images are supported for now
+		// Other types have no full view, their file is available via the download link
 		return nil
 	}
 	imgElement := el.DOM.Find(".submission-image img").First()
@@ -518,6 +657,20 @@ func submissionFullView(submissionType SubmissionType, el *colly.HTMLElement) *u
 	return parsed
 }
 
+// submissionDownload returns the link of the download button on a submission's view page.
+func submissionDownload(page *goquery.Selection) *url.URL {
+	href := page.Find(submissionSidebarSelector+" .download a").First().AttrOr("href", "")
+	if href == "" {
+		return nil
+	}
+	parsed, err := FurAffinityUrl().Parse(href)
+	if err != nil {
+		logging.Warnf("Failed parsing download URL for submission: %s", err)
+		return nil
+	}
+	return parsed
+}
+
 func parseSubmissionData(jsonData string) SubmissionDataMap {
 	// First, unmarshal into map[string]SubmissionData to preserve the string keys
 	var rawData map[string]*SubmissionData
@@ -0,0 +1,104 @@
+package fa
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fanonwue/goutils/dsext"
+
+	"github.com/senexdrake/furaffinity-notifier/internal/db"
+	"github.com/senexdrake/furaffinity-notifier/internal/fa/rules"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// TestSubmissionRules_ViewPageKeywords checks that include rules matching details of the view page don't drop the
+// submission before its content has been fetched.
+func TestSubmissionRules_ViewPageKeywords(t *testing.T) {
+	includeForest, err := rules.Parse(rules.ActionInclude, rules.FieldTags, "forest")
+	require.NoError(t, err)
+	includeDragon, err := rules.Parse(rules.ActionInclude, rules.FieldTags, "dragon")
+	require.NoError(t, err)
+	excludeFox, err := rules.Parse(rules.ActionExclude, rules.FieldTags, "fox")
+	require.NoError(t, err)
+
+	newEntry := func() *SubmissionEntry {
+		return &SubmissionEntry{id: 1, title: "Walk", rating: RatingGeneral, submissionType: SubmissionTypeImage}
+	}
+	withContent := func(entry *SubmissionEntry) *SubmissionEntry {
+		metadata := parseSubmissionMetadata(testDocument(t, testSubmissionSidebar).Selection)
+		require.NotNil(t, metadata)
+		entry.SetContent(&SubmissionContent{id: entry.ID(), metadata: metadata})
+		return entry
+	}
+
+	fc := FurAffinityCollector{Rules: rules.NewRuleSet(includeForest)}
+	entry := newEntry()
+	assert.True(t, fc.passesListingRules(entry), "the keyword is only known from the view page")
+	assert.True(t, fc.Rules.Passes(withContent(entry).RuleSubject()))
+
+	fc.Rules = rules.NewRuleSet(includeDragon)
+	assert.True(t, fc.passesListingRules(newEntry()))
+	assert.False(t, fc.Rules.Passes(withContent(newEntry()).RuleSubject()))
+
+	includeSpecies, err := rules.Parse(rules.ActionInclude, rules.FieldSpecies, "red fox")
+	require.NoError(t, err)
+	fc.Rules = rules.NewRuleSet(includeSpecies)
+	assert.False(t, fc.Rules.Passes(newEntry().RuleSubject()))
+	assert.True(t, fc.Rules.Passes(withContent(newEntry()).RuleSubject()), "the species is parsed from the view page")
+
+	fc.Rules = rules.NewRuleSet(excludeFox)
+	entry = newEntry()
+	entry.tags = dsext.NewSetSlice([]string{"fox"})
+	assert.False(t, fc.passesListingRules(entry), "exclude rules already apply to the listing")
+}
+
+// TestSubmissionRules_RejectedAreKnown checks that submissions rejected by the rules once their content is known are
+// not fetched again on the next run.
+func TestSubmissionRules_RejectedAreKnown(t *testing.T) {
+	require.NoError(t, db.DeleteUserData(1))
+
+	posted := time.Now().Add(-time.Hour)
+	views := atomic.Int32{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		views.Add(1)
+		fmt.Fprintf(w, `<html><body>
+<div class="submission-content">
+	<div class="submission-id-container"><span class="popup_date" data-time="%d">an hour ago</span></div>
+	<div class="submission-description">A walk through the woods</div>
+</div>%s</body></html>`, posted.Unix(), testSubmissionSidebar)
+	}))
+	t.Cleanup(server.Close)
+
+	previousUrl := furaffinityBaseUrl
+	serverUrl, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	furaffinityBaseUrl = serverUrl
+	t.Cleanup(func() { furaffinityBaseUrl = previousUrl })
+
+	includeDragon, err := rules.Parse(rules.ActionInclude, rules.FieldTags, "dragon")
+	require.NoError(t, err)
+	user := db.User{Model: gorm.Model{ID: 1}, Cookies: []db.UserCookie{}, EntryTypes: []db.UserEntryType{}}
+	fc := FurAffinityCollector{User: &user, LimitConcurrency: 1, Rules: rules.NewRuleSet(includeDragon)}
+
+	for range 2 {
+		listed := make(chan *SubmissionEntry, 1)
+		listed <- &SubmissionEntry{id: 1, title: "Walk", date: posted, rating: RatingGeneral, submissionType: SubmissionTypeText}
+		close(listed)
+
+		passed, _ := fc.submissionsWithContent(context.Background(), fc.filterNewSubmissions(listed, false), newRunReport())
+		for range passed {
+			assert.Fail(t, "the submission should have been rejected")
+		}
+	}
+
+	assert.Equal(t, int32(1), views.Load(), "the rejected submission should only be fetched once")
+	assert.False(t, fc.isSubmissionNew(1))
+}
@@ -0,0 +1,58 @@
+package schedule
+
+import (
+	"context"
+	"sync"
+)
+
+// UserLocks guarantees that at most one update runs per user at a time, regardless of how the run was started.
+type UserLocks struct {
+	mutex sync.Mutex
+	// locked holds a channel per locked user, which is closed once the user is unlocked
+	locked map[uint]chan struct{}
+}
+
+func NewUserLocks() *UserLocks {
+	return &UserLocks{locked: make(map[uint]chan struct{})}
+}
+
+// TryLock locks the given user. It returns false if an update for the user is already running.
+func (ul *UserLocks) TryLock(id uint) bool {
+	ul.mutex.Lock()
+	defer ul.mutex.Unlock()
+	if _, locked := ul.locked[id]; locked {
+		return false
+	}
+	ul.locked[id] = make(chan struct{})
+	return true
+}
+
+// Lock locks the given user, waiting for a running update to finish. It returns the error of the context if it is
+// done before the user could be locked.
+func (ul *UserLocks) Lock(ctx context.Context, id uint) error {
+	for {
+		ul.mutex.Lock()
+		unlocked, locked := ul.locked[id]
+		if !locked {
+			ul.locked[id] = make(chan struct{})
+			ul.mutex.Unlock()
+			return nil
+		}
+		ul.mutex.Unlock()
+
+		select {
+		case <-unlocked:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (ul *UserLocks) Unlock(id uint) {
+	ul.mutex.Lock()
+	defer ul.mutex.Unlock()
+	if unlocked, locked := ul.locked[id]; locked {
+		close(unlocked)
+		delete(ul.locked, id)
+	}
+}
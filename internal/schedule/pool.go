@@ -0,0 +1,44 @@
+package schedule
+
+import "sync"
+
+// Pool runs tasks on a bounded number of workers. Tasks are never queued: callers ask for the free capacity first and
+// only submit as many tasks as there are idle workers, so waiting work stays with the scheduler, which decides on a
+// fair order.
+type Pool struct {
+	slots chan struct{}
+	wg    sync.WaitGroup
+}
+
+func NewPool(workers int) *Pool {
+	return &Pool{slots: make(chan struct{}, max(workers, 1))}
+}
+
+// Size returns the amount of workers of the pool.
+func (p *Pool) Size() int {
+	return cap(p.slots)
+}
+
+// Free returns the amount of idle workers.
+func (p *Pool) Free() int {
+	return cap(p.slots) - len(p.slots)
+}
+
+// TryGo runs the task on an idle worker. It returns false without running the task if all workers are busy.
+func (p *Pool) TryGo(task func()) bool {
+	select {
+	case p.slots <- struct{}{}:
+	default:
+		return false
+	}
+	p.wg.Go(func() {
+		defer func() { <-p.slots }()
+		task()
+	})
+	return true
+}
+
+// Wait blocks until all running tasks are done.
+func (p *Pool) Wait() {
+	p.wg.Wait()
+}
@@ -0,0 +1,308 @@
+package schedule
+
+import (
+	"cmp"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+)
+
+type (
+	Config struct {
+		// BaseInterval is the interval new users start with and the base for error backoff
+		BaseInterval time.Duration
+		// MinInterval is the interval very active users converge to
+		MinInterval time.Duration
+		// MaxInterval is the interval idle users converge to
+		MaxInterval time.Duration
+		// MaxBackoff caps the delay after repeated errors
+		MaxBackoff time.Duration
+		// Jitter is the fraction by which each delay gets randomly stretched or shortened, e.g. 0.1 for ±10%
+		Jitter float64
+	}
+
+	// Candidate is a user that may be scheduled for an update.
+	Candidate struct {
+		ID uint
+		// Override is a fixed interval set by the user. Zero means the interval is adapted automatically.
+		Override time.Duration
+	}
+
+	// Outcome is the result of a single update run for a user.
+	Outcome struct {
+		NewEntries int
+		Err        error
+		// Duration is the time the run took
+		Duration time.Duration
+	}
+
+	// UserStats holds timing statistics of the update runs of a single user.
+	UserStats struct {
+		Runs          uint
+		Failures      uint
+		LastRun       time.Time
+		LastDuration  time.Duration
+		MaxDuration   time.Duration
+		TotalDuration time.Duration
+		// LastDelay is how late the last run started compared to its schedule, e.g. because all workers were busy
+		LastDelay time.Duration
+		MaxDelay  time.Duration
+	}
+
+	userState struct {
+		interval time.Duration
+		nextRun  time.Time
+		running  bool
+		failures uint
+		stats    UserStats
+	}
+
+	// Scheduler decides when each user should be updated next. Users that received new entries recently are polled
+	// more often, idle users less often. Errors cause an exponential backoff for the affected user, outages of FA
+	// itself pause the updates of all users.
+	Scheduler struct {
+		mutex          sync.Mutex
+		config         Config
+		users          map[uint]*userState
+		pausedUntil    time.Time
+		outageFailures uint
+	}
+)
+
+// activeFactor shrinks the interval after a run that found new entries, idleFactor grows it after a run that did not
+const (
+	activeFactor = 0.5
+	idleFactor   = 1.25
+)
+
+func New(config Config) *Scheduler {
+	if config.MinInterval > config.BaseInterval {
+		config.MinInterval = config.BaseInterval
+	}
+	if config.MaxInterval < config.BaseInterval {
+		config.MaxInterval = config.BaseInterval
+	}
+	if config.MaxBackoff < config.MaxInterval {
+		config.MaxBackoff = config.MaxInterval
+	}
+	return &Scheduler{
+		config: config,
+		users:  make(map[uint]*userState),
+	}
+}
+
+// Due returns the IDs of at most limit candidates that should be updated now and marks them as running. The users
+// that are overdue the longest come first, so users that did not fit into the limit are preferred in the next call.
+// Users that are not part of the candidates anymore are forgotten.
+func (s *Scheduler) Due(now time.Time, candidates []Candidate, limit int) []uint {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if now.Before(s.pausedUntil) {
+		return nil
+	}
+
+	known := make(map[uint]struct{}, len(candidates))
+	due := make([]uint, 0)
+	for _, candidate := range candidates {
+		known[candidate.ID] = struct{}{}
+		state, found := s.users[candidate.ID]
+		if !found {
+			// Spread the first run of new users over the base interval, so they don't all hit FA at the same time
+			state = &userState{
+				interval: s.config.BaseInterval,
+				nextRun:  now.Add(s.randomDuration(s.config.BaseInterval)),
+			}
+			s.users[candidate.ID] = state
+		}
+		if state.running || now.Before(state.nextRun) {
+			continue
+		}
+		due = append(due, candidate.ID)
+	}
+
+	slices.SortStableFunc(due, func(a, b uint) int {
+		return s.users[a].nextRun.Compare(s.users[b].nextRun)
+	})
+	due = due[:min(len(due), max(limit, 0))]
+	for _, id := range due {
+		state := s.users[id]
+		state.running = true
+		state.stats.LastDelay = now.Sub(state.nextRun)
+		state.stats.MaxDelay = max(state.stats.MaxDelay, state.stats.LastDelay)
+	}
+
+	for id, state := range s.users {
+		if _, found := known[id]; !found && !state.running {
+			delete(s.users, id)
+		}
+	}
+
+	return due
+}
+
+// Complete records the outcome of a user's update run and schedules the next one.
+func (s *Scheduler) Complete(now time.Time, candidate Candidate, outcome Outcome) time.Time {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	state, found := s.users[candidate.ID]
+	if !found {
+		state = &userState{interval: s.config.BaseInterval}
+		s.users[candidate.ID] = state
+	}
+	state.running = false
+	state.stats.record(now, outcome)
+
+	var delay time.Duration
+	if outcome.Err != nil {
+		state.failures++
+		delay = s.backoff(state.failures)
+	} else {
+		state.failures = 0
+		state.interval = s.adapt(state.interval, outcome.NewEntries)
+		delay = state.interval
+		if candidate.Override > 0 {
+			delay = max(candidate.Override, s.config.MinInterval)
+		}
+	}
+
+	state.nextRun = now.Add(s.applyJitter(delay))
+	return state.nextRun
+}
+
+// Release marks a user that was returned by Due as not running anymore without scheduling the next run, e.g. because
+// the run could not be started. The user is due again immediately.
+func (s *Scheduler) Release(id uint) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if state, found := s.users[id]; found {
+		state.running = false
+	}
+}
+
+// Postpone marks a user that was returned by Due as not running anymore and delays their next run until the given
+// time, e.g. because another update of the user is still running. The interval and the statistics stay unchanged.
+func (s *Scheduler) Postpone(id uint, until time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if state, found := s.users[id]; found {
+		state.running = false
+		state.nextRun = until
+	}
+}
+
+// Remove forgets a user, e.g. because they have been deleted, even if they are marked as running.
+func (s *Scheduler) Remove(id uint) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.users, id)
+}
+
+// ReportOutage pauses the updates of all users with an exponential backoff. Reports that arrive while the updates
+// are already paused, e.g. from runs that were started before the outage was detected, do not extend the pause. The
+// second return value is true if this report started a new outage.
+func (s *Scheduler) ReportOutage(now time.Time) (time.Time, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if now.Before(s.pausedUntil) {
+		return s.pausedUntil, false
+	}
+	s.outageFailures++
+	s.pausedUntil = now.Add(s.applyJitter(s.backoff(s.outageFailures)))
+	return s.pausedUntil, s.outageFailures == 1
+}
+
+// EndOutage resumes regular updates after a successful run. It returns true if an outage was in progress.
+func (s *Scheduler) EndOutage() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	ongoing := s.outageFailures > 0
+	s.outageFailures = 0
+	s.pausedUntil = time.Time{}
+	return ongoing
+}
+
+// NextRun returns the time the user is scheduled to be updated next.
+func (s *Scheduler) NextRun(id uint) (time.Time, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	state, found := s.users[id]
+	if !found {
+		return time.Time{}, false
+	}
+	return state.nextRun, true
+}
+
+// Stats returns the timing statistics of all users known to the scheduler.
+func (s *Scheduler) Stats() map[uint]UserStats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	stats := make(map[uint]UserStats, len(s.users))
+	for id, state := range s.users {
+		stats[id] = state.stats
+	}
+	return stats
+}
+
+// Slowest returns the IDs of the users whose last runs took the longest, slowest first.
+func Slowest(stats map[uint]UserStats, count int) []uint {
+	ids := slices.SortedFunc(maps.Keys(stats), func(a, b uint) int {
+		return cmp.Compare(stats[b].LastDuration, stats[a].LastDuration)
+	})
+	return ids[:min(len(ids), count)]
+}
+
+func (us *UserStats) record(now time.Time, outcome Outcome) {
+	us.Runs++
+	if outcome.Err != nil {
+		us.Failures++
+	}
+	us.LastRun = now
+	us.LastDuration = outcome.Duration
+	us.MaxDuration = max(us.MaxDuration, outcome.Duration)
+	us.TotalDuration += outcome.Duration
+}
+
+func (us UserStats) AverageDuration() time.Duration {
+	if us.Runs == 0 {
+		return 0
+	}
+	return us.TotalDuration / time.Duration(us.Runs)
+}
+
+func (s *Scheduler) adapt(interval time.Duration, newEntries int) time.Duration {
+	if newEntries > 0 {
+		interval = time.Duration(float64(interval) * activeFactor)
+	} else {
+		interval = time.Duration(float64(interval) * idleFactor)
+	}
+	return min(max(interval, s.config.MinInterval), s.config.MaxInterval)
+}
+
+func (s *Scheduler) backoff(failures uint) time.Duration {
+	delay := s.config.BaseInterval
+	for i := uint(1); i < failures && delay < s.config.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, s.config.MaxBackoff)
+}
+
+func (s *Scheduler) applyJitter(delay time.Duration) time.Duration {
+	if s.config.Jitter <= 0 {
+		return delay
+	}
+	spread := time.Duration(float64(delay) * s.config.Jitter)
+	return delay - spread + s.randomDuration(2*spread)
+}
+
+func (s *Scheduler) randomDuration(upTo time.Duration) time.Duration {
+	if upTo <= 0 {
+		return 0
+	}
+	return rand.N(upTo)
+}
@@ -0,0 +1,211 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testLimit = 10
+
+var testConfig = Config{
+	BaseInterval: 2 * time.Minute,
+	MinInterval:  time.Minute,
+	MaxInterval:  10 * time.Minute,
+	MaxBackoff:   time.Hour,
+}
+
+// startedScheduler returns a scheduler on which the first run of the given candidate is already due.
+func startedScheduler(t *testing.T, candidate Candidate) (*Scheduler, time.Time) {
+	s := New(testConfig)
+	now := time.Now()
+	s.Due(now, []Candidate{candidate}, testLimit)
+	now = now.Add(testConfig.BaseInterval)
+	require.Equal(t, []uint{candidate.ID}, s.Due(now, []Candidate{candidate}, testLimit))
+	return s, now
+}
+
+func TestScheduler_RunningUserIsNotDueTwice(t *testing.T) {
+	candidate := Candidate{ID: 1}
+	s, now := startedScheduler(t, candidate)
+	assert.Empty(t, s.Due(now.Add(time.Hour), []Candidate{candidate}, testLimit))
+}
+
+func TestScheduler_Adaptive(t *testing.T) {
+	tests := []struct {
+		name     string
+		outcome  Outcome
+		override time.Duration
+		expected time.Duration
+	}{
+		{name: "active user is polled more often", outcome: Outcome{NewEntries: 3}, expected: time.Minute},
+		{name: "idle user is polled less often", outcome: Outcome{}, expected: 150 * time.Second},
+		{name: "error backs off", outcome: Outcome{Err: errors.New("test")}, expected: 2 * time.Minute},
+		{name: "override wins", outcome: Outcome{NewEntries: 3}, override: 5 * time.Minute, expected: 5 * time.Minute},
+		{name: "override respects minimum", outcome: Outcome{}, override: time.Second, expected: time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			candidate := Candidate{ID: 1, Override: tt.override}
+			s, now := startedScheduler(t, candidate)
+			nextRun := s.Complete(now, candidate, tt.outcome)
+			assert.Equal(t, tt.expected, nextRun.Sub(now))
+		})
+	}
+}
+
+func TestScheduler_Bounds(t *testing.T) {
+	candidate := Candidate{ID: 1}
+	s, now := startedScheduler(t, candidate)
+
+	var nextRun time.Time
+	for range 20 {
+		nextRun = s.Complete(now, candidate, Outcome{})
+		s.Due(nextRun, []Candidate{candidate}, testLimit)
+	}
+	assert.Equal(t, testConfig.MaxInterval, nextRun.Sub(now))
+
+	for range 20 {
+		nextRun = s.Complete(now, candidate, Outcome{Err: errors.New("test")})
+		s.Due(nextRun, []Candidate{candidate}, testLimit)
+	}
+	assert.Equal(t, testConfig.MaxBackoff, nextRun.Sub(now))
+}
+
+func TestScheduler_ForgetsRemovedUsers(t *testing.T) {
+	s := New(testConfig)
+	s.Due(time.Now(), []Candidate{{ID: 1}}, testLimit)
+	s.Due(time.Now(), []Candidate{}, testLimit)
+	_, found := s.NextRun(1)
+	assert.False(t, found)
+}
+
+func TestScheduler_Remove(t *testing.T) {
+	candidate := Candidate{ID: 1}
+	s, now := startedScheduler(t, candidate)
+	// Running users are otherwise kept, even if they are not a candidate anymore
+	s.Remove(candidate.ID)
+	_, found := s.NextRun(candidate.ID)
+	assert.False(t, found)
+	assert.Empty(t, s.Due(now, []Candidate{}, testLimit))
+}
+
+func TestScheduler_Postpone(t *testing.T) {
+	candidate := Candidate{ID: 1}
+	s, now := startedScheduler(t, candidate)
+	s.Postpone(candidate.ID, now.Add(30*time.Second))
+	assert.Empty(t, s.Due(now.Add(5*time.Second), []Candidate{candidate}, testLimit))
+	assert.Equal(t, []uint{candidate.ID}, s.Due(now.Add(30*time.Second), []Candidate{candidate}, testLimit))
+
+	// Released users are due again immediately
+	s.Release(candidate.ID)
+	assert.Equal(t, []uint{candidate.ID}, s.Due(now.Add(30*time.Second), []Candidate{candidate}, testLimit))
+}
+
+func TestScheduler_Outage(t *testing.T) {
+	candidate := Candidate{ID: 1}
+	s, now := startedScheduler(t, candidate)
+	s.Complete(now, candidate, Outcome{})
+
+	pausedUntil, started := s.ReportOutage(now)
+	assert.True(t, started)
+	assert.Equal(t, now.Add(testConfig.BaseInterval), pausedUntil)
+
+	// Reports from runs that were already in progress do not extend the pause
+	again, started := s.ReportOutage(now.Add(time.Second))
+	assert.False(t, started)
+	assert.Equal(t, pausedUntil, again)
+
+	assert.Empty(t, s.Due(now.Add(time.Minute), []Candidate{candidate}, testLimit))
+
+	// The outage persists after the pause, so the next pause is twice as long
+	next, started := s.ReportOutage(pausedUntil)
+	assert.False(t, started)
+	assert.Equal(t, pausedUntil.Add(2*testConfig.BaseInterval), next)
+
+	assert.True(t, s.EndOutage())
+	assert.False(t, s.EndOutage())
+	assert.Equal(t, []uint{candidate.ID}, s.Due(now.Add(time.Hour), []Candidate{candidate}, testLimit))
+}
+
+func TestUserLocks(t *testing.T) {
+	locks := NewUserLocks()
+	assert.True(t, locks.TryLock(1))
+	assert.False(t, locks.TryLock(1))
+	assert.True(t, locks.TryLock(2))
+	locks.Unlock(1)
+	assert.True(t, locks.TryLock(1))
+}
+
+func TestUserLocks_Lock(t *testing.T) {
+	locks := NewUserLocks()
+	require.True(t, locks.TryLock(1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, locks.Lock(ctx, 1), context.DeadlineExceeded)
+
+	locked := make(chan error)
+	go func() { locked <- locks.Lock(context.Background(), 1) }()
+	select {
+	case <-locked:
+		t.Fatal("user should still be locked")
+	case <-time.After(10 * time.Millisecond):
+	}
+	locks.Unlock(1)
+	require.NoError(t, <-locked)
+	assert.False(t, locks.TryLock(1), "the waiting caller should hold the lock now")
+}
+
+func TestScheduler_LimitPrefersMostOverdue(t *testing.T) {
+	s := New(testConfig)
+	now := time.Now()
+	candidates := []Candidate{{ID: 1}, {ID: 2}, {ID: 3}}
+	s.Due(now, candidates, testLimit)
+	now = now.Add(testConfig.BaseInterval)
+	require.Len(t, s.Due(now, candidates, testLimit), 3)
+
+	// User 3 is overdue the longest, user 1 the shortest
+	s.Complete(now, candidates[0], Outcome{})
+	s.Complete(now.Add(-time.Minute), candidates[1], Outcome{})
+	s.Complete(now.Add(-2*time.Minute), candidates[2], Outcome{})
+
+	later := now.Add(time.Hour)
+	assert.Equal(t, []uint{3, 2}, s.Due(later, candidates, 2))
+	assert.Equal(t, []uint{1}, s.Due(later, candidates, 2))
+}
+
+func TestScheduler_Stats(t *testing.T) {
+	candidate := Candidate{ID: 1}
+	s, now := startedScheduler(t, candidate)
+	s.Complete(now, candidate, Outcome{Duration: 3 * time.Second})
+	s.Due(now.Add(time.Hour), []Candidate{candidate}, testLimit)
+	s.Complete(now.Add(time.Hour), candidate, Outcome{Duration: time.Second, Err: errors.New("test")})
+
+	stats := s.Stats()[candidate.ID]
+	assert.Equal(t, uint(2), stats.Runs)
+	assert.Equal(t, uint(1), stats.Failures)
+	assert.Equal(t, time.Second, stats.LastDuration)
+	assert.Equal(t, 3*time.Second, stats.MaxDuration)
+	assert.Equal(t, 2*time.Second, stats.AverageDuration())
+}
+
+func TestPool(t *testing.T) {
+	pool := NewPool(2)
+	release := make(chan struct{})
+	task := func() { <-release }
+
+	assert.True(t, pool.TryGo(task))
+	assert.True(t, pool.TryGo(task))
+	assert.False(t, pool.TryGo(task))
+	assert.Equal(t, 0, pool.Free())
+
+	close(release)
+	pool.Wait()
+	assert.Equal(t, 2, pool.Free())
+}
@@ -0,0 +1,218 @@
+// Package secrets implements envelope encryption for sensitive values stored in the database.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type (
+	// Key is a 256-bit key encrypting the data keys of values.
+	Key struct {
+		id   string
+		aead cipher.AEAD
+	}
+
+	// Keyring encrypts values with its primary key and decrypts values encrypted with any of its keys. Every value is
+	// encrypted with its own random data key, which is in turn encrypted with the key of the keyring. Rotating the key
+	// therefore only requires re-encrypting the data keys.
+	Keyring struct {
+		primary *Key
+		keys    map[string]*Key
+	}
+)
+
+// KeySize is the size of keys and data keys in bytes.
+const KeySize = 32
+
+// encryptedPrefix marks encrypted values, followed by the key ID, the encrypted data key and the encrypted value
+const encryptedPrefix = "enc:v1:"
+
+var (
+	ErrUnknownKey = errors.New("value was encrypted with an unknown key")
+	ErrMalformed  = errors.New("malformed encrypted value")
+)
+
+var encoding = base64.RawStdEncoding
+
+// NewKey creates a key from raw key material.
+func NewKey(raw []byte) (*Key, error) {
+	if len(raw) != KeySize {
+		return nil, fmt.Errorf("key must be %d bytes long, got %d", KeySize, len(raw))
+	}
+	aead, err := newAead(raw)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(raw)
+	return &Key{id: hex.EncodeToString(hash[:4]), aead: aead}, nil
+}
+
+// ParseKey creates a key from its base64 or hex encoded form.
+func ParseKey(encoded string) (*Key, error) {
+	encoded = strings.TrimSpace(encoded)
+	raw, err := hex.DecodeString(encoded)
+	if err != nil || len(raw) != KeySize {
+		raw, err = base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("key is neither valid hex nor base64")
+		}
+	}
+	return NewKey(raw)
+}
+
+// ID identifies the key in encrypted values without revealing it.
+func (k *Key) ID() string {
+	return k.id
+}
+
+// NewKeyring creates a keyring encrypting with the primary key. The old keys are only used to decrypt values that
+// have not been re-encrypted yet.
+func NewKeyring(primary *Key, old ...*Key) *Keyring {
+	keyring := Keyring{primary: primary, keys: map[string]*Key{primary.id: primary}}
+	for _, key := range old {
+		if _, found := keyring.keys[key.id]; !found {
+			keyring.keys[key.id] = key
+		}
+	}
+	return &keyring
+}
+
+// IsEncrypted returns true if the value has been encrypted by a keyring.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefix)
+}
+
+// Encrypt encrypts the value with a new data key. The associated data binds the value to its context, like the record it
+// is stored in, so it can only be decrypted with the same associated data. Copying the value to another record makes
+// it unusable.
+func (kr *Keyring) Encrypt(plaintext string, associatedData []byte) (string, error) {
+	dataKey := make([]byte, KeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+	dataAead, err := newAead(dataKey)
+	if err != nil {
+		return "", err
+	}
+	sealedValue, err := seal(dataAead, []byte(plaintext), associatedData)
+	if err != nil {
+		return "", err
+	}
+	return kr.wrap(dataKey, sealedValue)
+}
+
+// Decrypt decrypts the value with the associated data it has been encrypted with. Values that are not encrypted are
+// returned unchanged.
+func (kr *Keyring) Decrypt(value string, associatedData []byte) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	_, dataKey, sealedValue, err := kr.unwrap(value)
+	if err != nil {
+		return "", err
+	}
+	dataAead, err := newAead(dataKey)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(dataAead, sealedValue, associatedData)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// NeedsRotation returns true if the value is not encrypted or encrypted with a key other than the primary key.
+func (kr *Keyring) NeedsRotation(value string) bool {
+	if !IsEncrypted(value) {
+		return true
+	}
+	keyId, _, _ := strings.Cut(strings.TrimPrefix(value, encryptedPrefix), ":")
+	return keyId != kr.primary.id
+}
+
+// Rotate encrypts the data key of the value with the primary key, leaving the encrypted value itself untouched.
+// Values that are not encrypted yet are encrypted with the associated data.
+func (kr *Keyring) Rotate(value string, associatedData []byte) (string, error) {
+	if !IsEncrypted(value) {
+		return kr.Encrypt(value, associatedData)
+	}
+	key, dataKey, sealedValue, err := kr.unwrap(value)
+	if err != nil {
+		return "", err
+	}
+	if key == kr.primary {
+		return value, nil
+	}
+	return kr.wrap(dataKey, sealedValue)
+}
+
+func (kr *Keyring) wrap(dataKey []byte, sealedValue []byte) (string, error) {
+	wrappedKey, err := seal(kr.primary.aead, dataKey, nil)
+	if err != nil {
+		return "", err
+	}
+	return encryptedPrefix + kr.primary.id + ":" + encoding.EncodeToString(wrappedKey) + ":" +
+		encoding.EncodeToString(sealedValue), nil
+}
+
+func (kr *Keyring) unwrap(value string) (*Key, []byte, []byte, error) {
+	parts := strings.Split(strings.TrimPrefix(value, encryptedPrefix), ":")
+	if len(parts) != 3 {
+		return nil, nil, nil, ErrMalformed
+	}
+	key, found := kr.keys[parts[0]]
+	if !found {
+		return nil, nil, nil, fmt.Errorf("%w (key ID %s)", ErrUnknownKey, parts[0])
+	}
+	wrappedKey, err := encoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, nil, nil, ErrMalformed
+	}
+	sealedValue, err := encoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, nil, nil, ErrMalformed
+	}
+	dataKey, err := open(key.aead, wrappedKey, nil)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return key, dataKey, sealedValue, nil
+}
+
+func newAead(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts the plaintext and prepends the random nonce to it.
+func seal(aead cipher.AEAD, plaintext []byte, associatedData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, associatedData), nil
+}
+
+func open(aead cipher.AEAD, sealed []byte, associatedData []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrMalformed
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, associatedData)
+	if err != nil {
+		return nil, fmt.Errorf("decryption failed: %w", err)
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,124 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testContext = []byte("user_cookie:1:a")
+
+func testKey(t *testing.T, fill byte) *Key {
+	key, err := NewKey(bytes.Repeat([]byte{fill}, KeySize))
+	require.NoError(t, err)
+	return key
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	keyring := NewKeyring(testKey(t, 1))
+
+	encrypted, err := keyring.Encrypt("session-cookie", testContext)
+	require.NoError(t, err)
+	assert.True(t, IsEncrypted(encrypted))
+	assert.NotContains(t, encrypted, "session-cookie")
+	assert.False(t, keyring.NeedsRotation(encrypted))
+
+	decrypted, err := keyring.Decrypt(encrypted, testContext)
+	require.NoError(t, err)
+	assert.Equal(t, "session-cookie", decrypted)
+
+	again, err := keyring.Encrypt("session-cookie", testContext)
+	require.NoError(t, err)
+	assert.NotEqual(t, encrypted, again, "every value should use its own data key and nonce")
+}
+
+func TestDecrypt_OtherContext(t *testing.T) {
+	keyring := NewKeyring(testKey(t, 1))
+	encrypted, err := keyring.Encrypt("value", testContext)
+	require.NoError(t, err)
+
+	_, err = keyring.Decrypt(encrypted, []byte("user_cookie:2:a"))
+	assert.Error(t, err, "values copied to another context should not be readable")
+}
+
+func TestDecrypt_Plaintext(t *testing.T) {
+	keyring := NewKeyring(testKey(t, 1))
+	decrypted, err := keyring.Decrypt("plain", testContext)
+	require.NoError(t, err)
+	assert.Equal(t, "plain", decrypted)
+	assert.True(t, keyring.NeedsRotation("plain"))
+}
+
+func TestDecrypt_UnknownKey(t *testing.T) {
+	encrypted, err := NewKeyring(testKey(t, 1)).Encrypt("value", testContext)
+	require.NoError(t, err)
+
+	_, err = NewKeyring(testKey(t, 2)).Decrypt(encrypted, testContext)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+}
+
+func TestDecrypt_Tampered(t *testing.T) {
+	keyring := NewKeyring(testKey(t, 1))
+	encrypted, err := keyring.Encrypt("value", testContext)
+	require.NoError(t, err)
+
+	parts := strings.Split(encrypted, ":")
+	sealed, err := encoding.DecodeString(parts[len(parts)-1])
+	require.NoError(t, err)
+	sealed[len(sealed)-1] ^= 0xff
+	parts[len(parts)-1] = encoding.EncodeToString(sealed)
+
+	_, err = keyring.Decrypt(strings.Join(parts, ":"), testContext)
+	assert.Error(t, err)
+
+	_, err = keyring.Decrypt("enc:v1:garbage", testContext)
+	assert.ErrorIs(t, err, ErrMalformed)
+}
+
+func TestRotate(t *testing.T) {
+	oldKey, newKey := testKey(t, 1), testKey(t, 2)
+	encrypted, err := NewKeyring(oldKey).Encrypt("value", testContext)
+	require.NoError(t, err)
+
+	keyring := NewKeyring(newKey, oldKey)
+	assert.True(t, keyring.NeedsRotation(encrypted))
+
+	// Values encrypted with old keys can still be read before they have been rotated
+	decrypted, err := keyring.Decrypt(encrypted, testContext)
+	require.NoError(t, err)
+	assert.Equal(t, "value", decrypted)
+
+	rotated, err := keyring.Rotate(encrypted, testContext)
+	require.NoError(t, err)
+	assert.False(t, keyring.NeedsRotation(rotated))
+	assert.Equal(t, encrypted[strings.LastIndex(encrypted, ":"):], rotated[strings.LastIndex(rotated, ":"):],
+		"only the data key should be re-encrypted")
+
+	decrypted, err = NewKeyring(newKey).Decrypt(rotated, testContext)
+	require.NoError(t, err)
+	assert.Equal(t, "value", decrypted)
+
+	plainRotated, err := keyring.Rotate("plain", testContext)
+	require.NoError(t, err)
+	assert.True(t, IsEncrypted(plainRotated))
+}
+
+func TestParseKey(t *testing.T) {
+	raw := bytes.Repeat([]byte{7}, KeySize)
+	expected, err := NewKey(raw)
+	require.NoError(t, err)
+
+	for _, encoded := range []string{hex.EncodeToString(raw), base64.StdEncoding.EncodeToString(raw) + "\n"} {
+		key, err := ParseKey(encoded)
+		require.NoError(t, err)
+		assert.Equal(t, expected.ID(), key.ID())
+	}
+
+	_, err = ParseKey(base64.StdEncoding.EncodeToString([]byte("too short")))
+	assert.Error(t, err)
+}
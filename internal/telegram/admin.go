@@ -0,0 +1,163 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/fanonwue/goutils/dsext"
+	"github.com/fanonwue/goutils/logging"
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/senexdrake/furaffinity-notifier/internal/conf"
+	"github.com/senexdrake/furaffinity-notifier/internal/db"
+	"github.com/senexdrake/furaffinity-notifier/internal/fa"
+	"github.com/senexdrake/furaffinity-notifier/internal/util"
+)
+
+// backupOptions are used for backups created with the /backup command
+var backupOptions db.BackupOptions
+
+func SetBackupOptions(options db.BackupOptions) {
+	backupOptions = options
+}
+
+func creatorAvailable() bool {
+	return botInstance != nil && conf.TelegramCreatorId > 0
+}
+
+// adminCommandHandlers returns the commands only available to the bot creator. They are not shown to other users.
+func adminCommandHandlers() []*CommandHandler {
+	return []*CommandHandler{
+		{
+			Pattern:     "/backup",
+			Description: "Creates a backup of the database",
+			HandlerType: bot.HandlerTypeMessageText,
+			MatchType:   bot.MatchTypeExact,
+			HandlerFunc: creatorOnly(backupHandler),
+			ChatAction:  models.ChatActionTyping,
+		},
+	}
+}
+
+// registerCreatorCommands shows the given commands, including the admin commands, to the bot creator.
+func registerCreatorCommands(commands []*CommandHandler, tgBot *bot.Bot, ctx context.Context) {
+	if conf.TelegramCreatorId <= 0 {
+		return
+	}
+	_, err := tgBot.SetMyCommands(ctx, &bot.SetMyCommandsParams{
+		Commands: dsext.Map(commands, func(ch *CommandHandler) models.BotCommand {
+			return models.BotCommand{Command: ch.Pattern, Description: ch.Description}
+		}),
+		Scope: &models.BotCommandScopeChat{ChatID: conf.TelegramCreatorId},
+	})
+	if err != nil {
+		logging.Errorf("error registering creator commands: %s", err)
+	}
+}
+
+// creatorOnly ignores updates from anyone but the bot creator, regardless of whether the bot is public.
+func creatorOnly(next bot.HandlerFunc) bot.HandlerFunc {
+	return func(ctx context.Context, b *bot.Bot, update *models.Update) {
+		chatId, err := chatIdFromUpdate(update)
+		if err != nil || conf.TelegramCreatorId <= 0 || chatId != conf.TelegramCreatorId {
+			defaultHandler(ctx, b, update)
+			return
+		}
+		next(ctx, b, update)
+	}
+}
+
+func backupHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	chatId, _ := chatIdFromUpdate(update)
+	info, err := db.CreateBackup(backupOptions, time.Now())
+	text := ""
+	if err != nil {
+		logging.Errorf("error creating backup: %s", err)
+		text = fmt.Sprintf("Creating the backup failed: %s", html.EscapeString(err.Error()))
+	} else {
+		logging.Infof("Created backup %s (%s)", info.Path, util.FormatBytes(info.Size))
+		text = fmt.Sprintf("Created backup <code>%s</code> (%s)", html.EscapeString(info.Path), util.FormatBytes(info.Size))
+		if backupOptions.RedactCookies {
+			text += "\nCookies have been removed from the backup."
+		}
+	}
+	_, err = SendMessage(ctx, chatId, text)
+	logSendMessageError(err)
+}
+
+// HandleBackupFailed notifies the bot creator that a scheduled backup could not be created.
+func HandleBackupFailed(ctx context.Context, backupErr error) {
+	if !creatorAvailable() {
+		return
+	}
+
+	text := fmt.Sprintf("<b>WARNING:</b> Creating the scheduled backup failed: %s", html.EscapeString(backupErr.Error()))
+	_, err := SendMessage(ctx, conf.TelegramCreatorId, text)
+	if err != nil {
+		logging.Errorf("error sending backup failure notification: %s", err)
+	}
+}
+
+// HandleMarkupDrift notifies the bot creator about a page that does not look like the scrapers expect it to. The saved
+// copy of the page is only referenced by its path, as it contains the private messages of the affected user.
+func HandleMarkupDrift(ctx context.Context, report *fa.DriftReport) {
+	if !creatorAvailable() {
+		return
+	}
+
+	problems := make([]string, 0, len(report.Problems))
+	for _, problem := range report.Problems {
+		problems = append(problems, "- "+html.EscapeString(problem))
+	}
+
+	text := fmt.Sprintf(
+		"<b>WARNING:</b> Possible FA markup change detected on the <b>%s</b> page!\n\n%s\n\n%s\n\nEntries found: %d, parsed: %d",
+		report.Page,
+		html.EscapeString(report.Url),
+		strings.Join(problems, "\n"),
+		report.Found,
+		report.Parsed,
+	)
+	if report.DumpPath != "" {
+		text += fmt.Sprintf("\nA copy of the page has been saved on the server to <code>%s</code>", html.EscapeString(report.DumpPath))
+	}
+
+	_, err := SendMessage(ctx, conf.TelegramCreatorId, text)
+	if err != nil {
+		logging.Errorf("error sending markup drift notification: %s", err)
+	}
+}
+
+// HandleOutageStarted notifies the bot creator that FA is unavailable and updates have been paused.
+func HandleOutageStarted(ctx context.Context, unavailable *fa.UnavailableError, pausedUntil time.Time) {
+	if !creatorAvailable() || !conf.NotifyOutages() {
+		return
+	}
+
+	text := fmt.Sprintf(
+		"<b>WARNING:</b> FurAffinity is unavailable (<b>%s</b>, HTTP %d)!\n\n%s\n\nUpdates are paused until %s and will be retried with an increasing delay.",
+		unavailable.Kind,
+		unavailable.StatusCode,
+		html.EscapeString(unavailable.Url),
+		pausedUntil.Format(time.DateTime),
+	)
+	_, err := SendMessage(ctx, conf.TelegramCreatorId, text)
+	if err != nil {
+		logging.Errorf("error sending outage notification: %s", err)
+	}
+}
+
+// HandleOutageResolved notifies the bot creator that FA is available again.
+func HandleOutageResolved(ctx context.Context) {
+	if !creatorAvailable() || !conf.NotifyOutages() {
+		return
+	}
+
+	_, err := SendMessage(ctx, conf.TelegramCreatorId, "FurAffinity is available again, updates have been resumed.")
+	if err != nil {
+		logging.Errorf("error sending outage resolved notification: %s", err)
+	}
+}
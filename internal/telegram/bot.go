@@ -4,6 +4,10 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"path"
 	"slices"
 	"strings"
 	"time"
@@ -16,7 +20,9 @@ import (
 	"github.com/senexdrake/furaffinity-notifier/internal/db"
 	"github.com/senexdrake/furaffinity-notifier/internal/fa"
 	"github.com/senexdrake/furaffinity-notifier/internal/fa/entries"
+	"github.com/senexdrake/furaffinity-notifier/internal/fa/tools"
 	"github.com/senexdrake/furaffinity-notifier/internal/tmpl"
+	"github.com/senexdrake/furaffinity-notifier/internal/util"
 	"gorm.io/gorm"
 )
 
@@ -26,10 +32,19 @@ var privacyPolicyCommand = createPrivacyPolicyCommand()
 
 var convHandler *ConversationHandler
 
+// deliveryTimeout limits how long sending a single notification may take. Deliveries are detached from the
+// cancellation of their context, so a notification that is already being sent is not lost during shutdown.
+const deliveryTimeout = 30 * time.Second
+
+// maxStoryFileSize limits the size of story files sent as documents. Bots may upload up to 50 MB, but stories are
+// usually far smaller than this.
+const maxStoryFileSize = 20 << 20
+
 const (
 	stageCookieInput = iota + 1
 	stageSettings
 	stageTimezoneInput
+	stageDeleteConfirmation
 )
 
 func StartBot(ctx context.Context) *bot.Bot {
@@ -44,9 +59,10 @@ func StartBot(ctx context.Context) *bot.Bot {
 	}
 
 	convHandler = NewConversationHandler(map[int]bot.HandlerFunc{
-		stageCookieInput:   cookieInputHandler,
-		stageSettings:      onSettingsKeyboardSelect,
-		stageTimezoneInput: timezoneInputHandler,
+		stageCookieInput:        cookieInputHandler,
+		stageSettings:           onSettingsKeyboardSelect,
+		stageTimezoneInput:      timezoneInputHandler,
+		stageDeleteConfirmation: deleteConfirmationHandler,
 	}, &convEnd)
 
 	opts := []bot.Option{
@@ -61,9 +77,11 @@ func StartBot(ctx context.Context) *bot.Bot {
 	}
 
 	commands := commandHandlers()
+	adminCommands := adminCommandHandlers()
 
-	registerHandlers(commands, b, botContext)
+	registerHandlers(slices.Concat(commands, adminCommands, callbackHandlers()), b, botContext)
 	registerCommands(commands, b, botContext)
+	registerCreatorCommands(slices.Concat(commands, adminCommands), b, botContext)
 
 	go func() {
 		defer botContextCancel()
@@ -143,6 +161,86 @@ func commandHandlers() []*CommandHandler {
 			HandlerFunc: unreadOnlyHandler,
 			ChatAction:  models.ChatActionTyping,
 		},
+		{
+			Pattern:     "/interval",
+			Description: "Sets a fixed update interval or enables the adaptive interval",
+			HandlerType: bot.HandlerTypeMessageText,
+			MatchType:   bot.MatchTypePrefix,
+			HandlerFunc: intervalHandler,
+			ChatAction:  models.ChatActionTyping,
+		},
+		{
+			Pattern:     "/blocked_tags",
+			Description: "Manages your extra blocked tags and how blocked submissions are sent",
+			HandlerType: bot.HandlerTypeMessageText,
+			MatchType:   bot.MatchTypePrefix,
+			HandlerFunc: blockedTagsHandler,
+			ChatAction:  models.ChatActionTyping,
+		},
+		{
+			Pattern:     "/filter",
+			Description: "Allows or denies notifications from specific FA users",
+			HandlerType: bot.HandlerTypeMessageText,
+			MatchType:   bot.MatchTypePrefix,
+			HandlerFunc: filterHandler,
+			ChatAction:  models.ChatActionTyping,
+		},
+		{
+			Pattern:     "/rules",
+			Description: "Manages keyword and regex rules for submissions",
+			HandlerType: bot.HandlerTypeMessageText,
+			MatchType:   bot.MatchTypePrefix,
+			HandlerFunc: rulesHandler,
+			ChatAction:  models.ChatActionTyping,
+		},
+		{
+			Pattern:     "/tier",
+			Description: "Marks FA users as priority or muted artists",
+			HandlerType: bot.HandlerTypeMessageText,
+			MatchType:   bot.MatchTypePrefix,
+			HandlerFunc: tierHandler,
+			ChatAction:  models.ChatActionTyping,
+		},
+		{
+			Pattern:     "/export",
+			Description: "Sends you all data stored about you",
+			HandlerType: bot.HandlerTypeMessageText,
+			MatchType:   bot.MatchTypeExact,
+			HandlerFunc: exportHandler,
+			ChatAction:  models.ChatActionUploadDocument,
+		},
+		{
+			Pattern:     "/delete_me",
+			Description: "Deletes all data stored about you",
+			HandlerType: bot.HandlerTypeMessageText,
+			MatchType:   bot.MatchTypeExact,
+			HandlerFunc: deleteMeHandler,
+			ChatAction:  models.ChatActionTyping,
+		},
+		{
+			Pattern:     "/history",
+			Description: "Lists the notifications you have received",
+			HandlerType: bot.HandlerTypeMessageText,
+			MatchType:   bot.MatchTypePrefix,
+			HandlerFunc: historyHandler,
+			ChatAction:  models.ChatActionTyping,
+		},
+		{
+			Pattern:     "/search",
+			Description: "Searches the notifications you have received",
+			HandlerType: bot.HandlerTypeMessageText,
+			MatchType:   bot.MatchTypePrefix,
+			HandlerFunc: searchHandler,
+			ChatAction:  models.ChatActionTyping,
+		},
+		{
+			Pattern:     "/stats",
+			Description: "Shows statistics about your notifications",
+			HandlerType: bot.HandlerTypeMessageText,
+			MatchType:   bot.MatchTypeExact,
+			HandlerFunc: statsHandler,
+			ChatAction:  models.ChatActionTyping,
+		},
 		{
 			Pattern:     "/settings",
 			Description: "Change notification settings",
@@ -174,8 +272,115 @@ func commandHandlers() []*CommandHandler {
 	return commands
 }
 
-func HandleInvalidCredentials(user *db.User, updateDatabase bool) {
-	_, err := botInstance.SendMessage(botContext, &bot.SendMessageParams{
+// callbackHandlers handle the buttons of messages outside of conversations. They are not listed as commands.
+func callbackHandlers() []*CommandHandler {
+	return []*CommandHandler{
+		{
+			Pattern:     historyButtonDataPrefix,
+			HandlerType: bot.HandlerTypeCallbackQueryData,
+			MatchType:   bot.MatchTypePrefix,
+			HandlerFunc: onHistoryPageSelect,
+		},
+		{
+			Pattern:     searchButtonDataPrefix,
+			HandlerType: bot.HandlerTypeCallbackQueryData,
+			MatchType:   bot.MatchTypePrefix,
+			HandlerFunc: onHistoryPageSelect,
+		},
+	}
+}
+
+func deliveryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), deliveryTimeout)
+}
+
+// delivery describes how a single notification is sent, depending on the tier of the FA user the entry is from.
+type delivery struct {
+	tier db.ArtistTier
+}
+
+func newDelivery(user *db.User, entry fa.BaseEntry) delivery {
+	if entry.From() == nil {
+		return delivery{tier: db.ArtistTierNormal}
+	}
+	return delivery{tier: user.ArtistTierOf(entry.From().UserName)}
+}
+
+// Silent returns true if the notification should be sent without a sound.
+func (d delivery) Silent() bool {
+	return d.tier == db.ArtistTierMuted
+}
+
+// Text marks the notification text of priority artists.
+func (d delivery) Text(text string) string {
+	if d.tier != db.ArtistTierPriority {
+		return text
+	}
+	return fmt.Sprintf("%c <b>Priority artist</b>\n%s", util.EmojiStar, text)
+}
+
+// deliver sends a notification about the entry exactly once. The entry is claimed in the database before sending, so
+// overlapping update runs can't deliver it twice, and recorded as known once it has been sent. The send function
+// returns the sent message, or nil if the user has not been notified.
+func deliver(ctx context.Context, user *db.User, entry fa.BaseEntry, send func(ctx context.Context, d delivery) (*models.Message, error)) {
+	claim, claimed, err := db.ClaimEntry(user.ID, entry.EntryType(), entry.ID())
+	if err != nil {
+		logging.Errorf("error claiming '%s' %d for user %d: %v", entry.EntryType().Name(), entry.ID(), user.ID, err)
+		return
+	}
+	if !claimed {
+		logging.Debugf("'%s' %d is already being delivered to user %d, skipping", entry.EntryType().Name(), entry.ID(), user.ID)
+		return
+	}
+
+	ctx, cancel := deliveryContext(ctx)
+	defer cancel()
+	message, err := send(ctx, newDelivery(user, entry))
+	if err != nil {
+		logging.Errorf("error sending '%s' notification: %v", entry.EntryType().Name(), err)
+		err = db.ReleaseEntry(claim)
+		if err != nil {
+			logging.Errorf("error releasing claim of '%s' %d for user %d: %v", entry.EntryType().Name(), entry.ID(), user.ID, err)
+		}
+		return
+	}
+
+	err = db.ConfirmEntry(claim, entry.Date())
+	if err != nil {
+		// Keep the claim, so the entry is not delivered again before the claim times out
+		logging.Errorf("error recording '%s' %d as known for user %d: %v", entry.EntryType().Name(), entry.ID(), user.ID, err)
+	}
+	if message != nil {
+		recordNotification(user, entry, message)
+	}
+}
+
+// recordNotification adds the delivered notification to the history of the user.
+func recordNotification(user *db.User, entry fa.BaseEntry, message *models.Message) {
+	record := db.NotificationRecord{
+		UserID:     user.ID,
+		EntryType:  entry.EntryType(),
+		EntryID:    entry.ID(),
+		Title:      entry.Title(),
+		Date:       entry.Date(),
+		MessageID:  message.ID,
+		NotifiedAt: time.Now(),
+	}
+	if entry.From() != nil {
+		record.Author = entry.From().UserName
+	}
+	if entry.Link() != nil {
+		record.Link = entry.Link().String()
+	}
+	if err := db.RecordNotification(&record); err != nil {
+		logging.Errorf("error recording notification of '%s' %d for user %d: %v", entry.EntryType().Name(), entry.ID(), user.ID, err)
+	}
+}
+
+func HandleInvalidCredentials(ctx context.Context, user *db.User, updateDatabase bool) {
+	ctx, cancel := deliveryContext(ctx)
+	defer cancel()
+	_, err := botInstance.SendMessage(ctx, &bot.SendMessageParams{
 		ChatID:    user.TelegramChatId,
 		ParseMode: models.ParseModeHTML,
 		Text:      "Your cookies are invalid. Please set them again using the /cookies command.",
@@ -189,7 +394,7 @@ func HandleInvalidCredentials(user *db.User, updateDatabase bool) {
 	}
 }
 
-func HandleNewNote(summary *fa.NoteEntry, user *db.User) {
+func HandleNewNote(ctx context.Context, summary *fa.NoteEntry, user *db.User) {
 	noteContent := "-- NO CONTENT --"
 	if summary.HasContent() {
 		noteContent = summary.Content().Text()
@@ -209,28 +414,28 @@ func HandleNewNote(summary *fa.NoteEntry, user *db.User) {
 		return
 	}
 
-	_, err = botInstance.SendMessage(botContext, &bot.SendMessageParams{
-		ChatID:             user.TelegramChatId,
-		ParseMode:          models.ParseModeHTML,
-		Text:               buf.String(),
-		LinkPreviewOptions: defaultLinkPreviewOptions(),
+	deliver(ctx, user, summary, func(ctx context.Context, d delivery) (*models.Message, error) {
+		return botInstance.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:              user.TelegramChatId,
+			ParseMode:           models.ParseModeHTML,
+			Text:                d.Text(buf.String()),
+			DisableNotification: d.Silent(),
+			LinkPreviewOptions:  defaultLinkPreviewOptions(),
+		})
 	})
+}
 
-	if err != nil {
-		logging.Errorf("error sending note notification: %v", err)
+func HandleNewSubmission(ctx context.Context, submission *fa.SubmissionEntry, user *db.User) {
+	blockedTagMode := db.BlockedTagModeWarn
+	if submission.IsBlocked() {
+		blockedTagMode = user.BlockedTagMode
+	}
+	if blockedTagMode == db.BlockedTagModeDrop {
+		// Record the submission as known without notifying, so it is not considered new again
+		deliver(ctx, user, submission, func(context.Context, delivery) (*models.Message, error) { return nil, nil })
 		return
 	}
 
-	db.Db().Create(&db.KnownEntry{
-		EntryType:  entries.EntryTypeNote,
-		ID:         summary.ID(),
-		UserID:     user.ID,
-		NotifiedAt: new(time.Now()),
-		SentDate:   summary.Date(),
-	})
-}
-
-func HandleNewSubmission(submission *fa.SubmissionEntry, user *db.User) {
 	fullViewUrl := submission.FullView()
 	fullViewUrlString := ""
 	if fullViewUrl != nil {
@@ -243,6 +448,12 @@ func HandleNewSubmission(submission *fa.SubmissionEntry, user *db.User) {
 		thumbnailUrlString = thumbnailUrl.String()
 	}
 
+	downloadUrl := submission.Download()
+	downloadUrlString := ""
+	if downloadUrl != nil {
+		downloadUrlString = downloadUrl.String()
+	}
+
 	buf := new(bytes.Buffer)
 	err := newSubmissionMessageTemplate.Execute(buf, &tmpl.NewSubmissionsContent{
 		ID:           submission.ID(),
@@ -254,7 +465,11 @@ func HandleNewSubmission(submission *fa.SubmissionEntry, user *db.User) {
 		Type:         submission.Type(),
 		ThumbnailUrl: thumbnailUrlString,
 		FullViewUrl:  fullViewUrlString,
+		DownloadUrl:  downloadUrlString,
 		Blocked:      submission.IsBlocked(),
+		BlockedTags:  slices.Sorted(maps.Keys(submission.BlockedReasons())),
+		HideContent:  blockedTagMode == db.BlockedTagModeHideContent,
+		Metadata:     submission.Metadata(),
 	})
 
 	if err != nil {
@@ -267,28 +482,79 @@ func HandleNewSubmission(submission *fa.SubmissionEntry, user *db.User) {
 		previewOptions.SetDisabled(true)
 	}
 
-	_, err = botInstance.SendMessage(botContext, &bot.SendMessageParams{
-		ChatID:             user.TelegramChatId,
-		ParseMode:          models.ParseModeHTML,
-		Text:               buf.String(),
-		LinkPreviewOptions: previewOptions.Get(),
+	sendStory := submission.Type() == fa.SubmissionTypeText && downloadUrl != nil &&
+		!submission.IsBlocked() && conf.SendStoryFiles()
+
+	deliver(ctx, user, submission, func(ctx context.Context, d delivery) (*models.Message, error) {
+		message, err := botInstance.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:              user.TelegramChatId,
+			ParseMode:           models.ParseModeHTML,
+			Text:                d.Text(buf.String()),
+			DisableNotification: d.Silent(),
+			LinkPreviewOptions:  previewOptions.Get(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		if blockedTagMode == db.BlockedTagModeSpoiler {
+			err = sendSpoilerPreview(ctx, user.TelegramChatId, message.ID, fullViewUrl, thumbnailUrl)
+			if err != nil {
+				logging.Warnf("error sending spoiler preview of submission %d: %v", submission.ID(), err)
+			}
+		}
+		if !sendStory {
+			return message, nil
+		}
+		// The notification has been sent at this point, so a failure here must not cause it to be sent again
+		err = sendStoryFile(ctx, user.TelegramChatId, message.ID, downloadUrl)
+		if err != nil {
+			logging.Warnf("error sending story file of submission %d: %v", submission.ID(), err)
+		}
+		return message, nil
 	})
+}
 
-	if err != nil {
-		logging.Errorf("error sending submission notification: %v", err)
-		return
+// sendSpoilerPreview sends the preview image of a blocked submission hidden behind a spoiler as a reply to the
+// notification.
+func sendSpoilerPreview(ctx context.Context, chatId int64, replyTo int, fullView *url.URL, thumbnail *tools.ThumbnailUrl) error {
+	previewUrl := fullView
+	if thumbnail != nil {
+		// Telegram refuses to fetch very large images, so the thumbnail is preferred
+		previewUrl = thumbnail.ToUrl()
 	}
+	if previewUrl == nil {
+		return nil
+	}
+	_, err := botInstance.SendPhoto(ctx, &bot.SendPhotoParams{
+		ChatID:              chatId,
+		Photo:               &models.InputFileString{Data: previewUrl.String()},
+		HasSpoiler:          true,
+		ReplyParameters:     &models.ReplyParameters{MessageID: replyTo},
+		DisableNotification: true,
+	})
+	return err
+}
 
-	db.Db().Create(&db.KnownEntry{
-		EntryType:  entries.EntryTypeSubmission,
-		ID:         submission.ID(),
-		UserID:     user.ID,
-		NotifiedAt: new(time.Now()),
-		SentDate:   submission.Date(),
+// sendStoryFile downloads the file of a text submission and sends it as a reply to the notification. The file has to
+// be uploaded, as Telegram only accepts a few file types when sending documents by URL.
+func sendStoryFile(ctx context.Context, chatId int64, replyTo int, downloadUrl *url.URL) error {
+	data, err := downloadFile(ctx, downloadUrl, maxStoryFileSize)
+	if err != nil {
+		return err
+	}
+	_, err = botInstance.SendDocument(ctx, &bot.SendDocumentParams{
+		ChatID: chatId,
+		Document: &models.InputFileUpload{
+			Filename: path.Base(downloadUrl.Path),
+			Data:     bytes.NewReader(data),
+		},
+		ReplyParameters:     &models.ReplyParameters{MessageID: replyTo},
+		DisableNotification: true,
 	})
+	return err
 }
 
-func HandleNewEntry(entry fa.Entry, user *db.User) {
+func HandleNewEntry(ctx context.Context, entry fa.Entry, user *db.User) {
 	entryContent := "-- NO CONTENT --"
 	if entry.HasContent() {
 		entryContent = entry.Content().Text()
@@ -335,29 +601,21 @@ func HandleNewEntry(entry fa.Entry, user *db.User) {
 		return
 	}
 
-	_, err := botInstance.SendMessage(botContext, &bot.SendMessageParams{
-		ChatID:             user.TelegramChatId,
-		ParseMode:          models.ParseModeHTML,
-		Text:               buf.String(),
-		LinkPreviewOptions: linkPreviewOptions.Get(),
-	})
-
-	if err != nil {
-		logging.Errorf("error sending entry notification: %v", err)
-		return
-	}
-
-	db.Db().Create(&db.KnownEntry{
-		EntryType:  entry.EntryType(),
-		ID:         entry.ID(),
-		UserID:     user.ID,
-		NotifiedAt: new(time.Now()),
-		SentDate:   entry.Date(),
+	deliver(ctx, user, entry, func(ctx context.Context, d delivery) (*models.Message, error) {
+		return botInstance.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:              user.TelegramChatId,
+			ParseMode:           models.ParseModeHTML,
+			Text:                d.Text(buf.String()),
+			DisableNotification: d.Silent(),
+			LinkPreviewOptions:  linkPreviewOptions.Get(),
+		})
 	})
 }
 
-func SendMessage(chatId int64, message string) (*models.Message, error) {
-	msg, err := botInstance.SendMessage(botContext, &bot.SendMessageParams{
+func SendMessage(ctx context.Context, chatId int64, message string) (*models.Message, error) {
+	ctx, cancel := deliveryContext(ctx)
+	defer cancel()
+	msg, err := botInstance.SendMessage(ctx, &bot.SendMessageParams{
 		ChatID:    chatId,
 		ParseMode: models.ParseModeHTML,
 		Text:      message,
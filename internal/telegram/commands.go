@@ -1,8 +1,15 @@
 package telegram
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"html"
+	"maps"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
@@ -11,7 +18,10 @@ import (
 	"github.com/fanonwue/goutils/logging"
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
+	"github.com/senexdrake/furaffinity-notifier/internal/conf"
 	"github.com/senexdrake/furaffinity-notifier/internal/db"
+	"github.com/senexdrake/furaffinity-notifier/internal/fa/entries"
+	"github.com/senexdrake/furaffinity-notifier/internal/fa/rules"
 	"gorm.io/gorm"
 )
 
@@ -240,6 +250,467 @@ func unreadOnlyHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
 	logSendMessageError(err)
 }
 
+func intervalHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	chatId, _ := chatIdFromUpdate(update)
+	user, userFound := userFromChatId(chatId, nil)
+	if !userFound {
+		_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatId,
+			Text:   "No user found for your Chat ID. Have you registered using the /start command?",
+		})
+		logSendMessageError(err)
+		return
+	}
+
+	intervalStatus := func(u *db.User) string {
+		if u.UpdateIntervalSeconds == 0 {
+			return "adaptive"
+		}
+		return fmt.Sprintf("%d seconds", u.UpdateIntervalSeconds)
+	}
+
+	messageParts := dsext.Filter(strings.Split(update.Message.Text, " "), func(s string) bool {
+		return s != ""
+	})
+
+	// First message part is always the command
+	if len(messageParts) < 2 {
+		_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:    chatId,
+			ParseMode: models.ParseModeHTML,
+			Text: fmt.Sprintf("Please provide an interval in seconds or 'auto' for an adaptive interval. Usage example:"+
+				"\n\n/interval 300"+
+				"\n\nIt is currently set to <b>%s</b>", intervalStatus(user)),
+		})
+		logSendMessageError(err)
+		return
+	}
+
+	seconds := uint64(0)
+	if !strings.EqualFold(messageParts[1], "auto") {
+		var err error
+		seconds, err = strconv.ParseUint(messageParts[1], 10, 32)
+		minimum := conf.MinimumUpdateInterval.Seconds()
+		if err != nil || float64(seconds) < minimum {
+			_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+				ChatID: chatId,
+				Text:   fmt.Sprintf("Please provide a whole number of seconds of at least %.0f, or 'auto'.", minimum),
+			})
+			logSendMessageError(err)
+			return
+		}
+	}
+
+	user.UpdateIntervalSeconds = uint(seconds)
+	db.Db().Save(user)
+
+	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:    chatId,
+		ParseMode: models.ParseModeHTML,
+		Text:      fmt.Sprintf("Update interval set to <b>%s</b>", intervalStatus(user)),
+	})
+	logSendMessageError(err)
+}
+
+func blockedTagsHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	chatId, _ := chatIdFromUpdate(update)
+	user, userFound := userFromChatId(chatId, nil)
+	if !userFound {
+		_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatId,
+			Text:   "No user found for your Chat ID. Have you registered using the /start command?",
+		})
+		logSendMessageError(err)
+		return
+	}
+
+	blockedTagsStatus := func(u *db.User) string {
+		tags := slices.Sorted(maps.Keys(u.ExtraBlockedTags()))
+		tagList := "none"
+		if len(tags) > 0 {
+			tagList = strings.Join(dsext.Map(tags, html.EscapeString), " ")
+		}
+		return fmt.Sprintf("Mode: <b>%s</b>\nExtra blocked tags: <code>%s</code>", u.BlockedTagMode, tagList)
+	}
+
+	reply := func(text string) {
+		_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:    chatId,
+			ParseMode: models.ParseModeHTML,
+			Text:      text,
+		})
+		logSendMessageError(err)
+	}
+
+	messageParts := dsext.Filter(strings.Split(update.Message.Text, " "), func(s string) bool {
+		return s != ""
+	})
+
+	// First message part is always the command
+	if len(messageParts) < 2 {
+		modes := dsext.Map(db.BlockedTagModes(), db.BlockedTagMode.String)
+		reply(fmt.Sprintf("Submissions containing tags on your FA blocklist or your extra blocked tags are handled "+
+			"according to the mode (%s). Usage examples:"+
+			"\n\n/blocked_tags mode spoiler"+
+			"\n/blocked_tags add tag1 tag2"+
+			"\n/blocked_tags remove tag1"+
+			"\n\n%s", strings.Join(modes, ", "), blockedTagsStatus(user)))
+		return
+	}
+
+	var err error
+	switch strings.ToLower(messageParts[1]) {
+	case "mode":
+		if len(messageParts) < 3 {
+			reply(blockedTagsStatus(user))
+			return
+		}
+		mode, parseErr := db.ParseBlockedTagMode(messageParts[2])
+		if parseErr != nil {
+			reply(parseErr.Error())
+			return
+		}
+		user.BlockedTagMode = mode
+		err = db.Db().Save(user).Error
+	case "add":
+		err = user.AddBlockedTags(messageParts[2:], nil)
+	case "remove":
+		err = user.RemoveBlockedTags(messageParts[2:], nil)
+	default:
+		reply("Unknown option. Please use 'mode', 'add' or 'remove'.")
+		return
+	}
+
+	if err != nil {
+		logging.Errorf("Error updating blocked tags of user %d: %v", user.ID, err)
+		reply("Error saving your blocked tags, please try again later.")
+		return
+	}
+
+	// Reload the tags
+	user.BlockedTags = nil
+	reply(blockedTagsStatus(user))
+}
+
+// filterEntryTypes maps the entry type arguments of the /filter command to the entry types they stand for
+var filterEntryTypes = map[string][]entries.EntryType{
+	"notes":               {entries.EntryTypeNote},
+	"submissions":         {entries.EntryTypeSubmission},
+	"journals":            {entries.EntryTypeJournal},
+	"submission_comments": {entries.EntryTypeSubmissionComment},
+	"journal_comments":    {entries.EntryTypeJournalComment},
+	"comments":            {entries.EntryTypeSubmissionComment, entries.EntryTypeJournalComment},
+}
+
+func filterHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	chatId, _ := chatIdFromUpdate(update)
+	user, userFound := userFromChatId(chatId, nil)
+	if !userFound {
+		_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatId,
+			Text:   "No user found for your Chat ID. Have you registered using the /start command?",
+		})
+		logSendMessageError(err)
+		return
+	}
+
+	reply := func(text string) {
+		_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:    chatId,
+			ParseMode: models.ParseModeHTML,
+			Text:      text,
+		})
+		logSendMessageError(err)
+	}
+
+	messageParts := dsext.Filter(strings.Split(update.Message.Text, " "), func(s string) bool {
+		return s != ""
+	})
+
+	// First message part is always the command
+	if len(messageParts) < 2 || strings.EqualFold(messageParts[1], "list") {
+		reply(userFilterList(user))
+		return
+	}
+
+	usage := "Usage examples:" +
+		"\n\n/filter add submissions artist1 artist2" +
+		"\n/filter deny comments someone" +
+		"\n/filter remove submissions artist1" +
+		"\n/filter list" +
+		"\n\nTypes: " + strings.Join(slices.Sorted(maps.Keys(filterEntryTypes)), ", ")
+
+	if len(messageParts) < 4 {
+		reply(usage)
+		return
+	}
+	entryTypes, found := filterEntryTypes[strings.ToLower(messageParts[2])]
+	if !found {
+		reply(fmt.Sprintf("Unknown type <code>%s</code>. %s", html.EscapeString(messageParts[2]), usage))
+		return
+	}
+	usernames := messageParts[3:]
+
+	action := strings.ToLower(messageParts[1])
+	txErr := db.Db().Transaction(func(tx *gorm.DB) error {
+		for _, entryType := range entryTypes {
+			for _, username := range usernames {
+				var err error
+				switch action {
+				case "add", "allow":
+					err = user.SetUserFilter(entryType, username, db.FilterKindAllow, tx)
+				case "deny":
+					err = user.SetUserFilter(entryType, username, db.FilterKindDeny, tx)
+				case "remove":
+					_, err = user.RemoveUserFilter(entryType, username, tx)
+				default:
+					return errUnknownFilterAction
+				}
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if errors.Is(txErr, errUnknownFilterAction) {
+		reply(fmt.Sprintf("Unknown option <code>%s</code>. %s", html.EscapeString(messageParts[1]), usage))
+		return
+	}
+	if txErr != nil {
+		logging.Errorf("Error updating filters of user %d: %v", user.ID, txErr)
+		reply("Error saving your filters, please try again later.")
+		return
+	}
+
+	reply(userFilterList(user))
+}
+
+var errUnknownFilterAction = errors.New("unknown filter action")
+
+// userFilterList lists the filters of the user per entry type. The filters configured via environment variables are
+// listed for entry types the user has no allow-list for.
+func userFilterList(user *db.User) string {
+	allowed := user.UserFilterLists(db.FilterKindAllow)
+	denied := user.UserFilterLists(db.FilterKindDeny)
+	defaults := conf.EntryUserFilters()
+
+	lines := make([]string, 0)
+	for _, entryType := range entries.ValidEntryTypes() {
+		allowList, defaultList := allowed[entryType], false
+		if len(allowList) == 0 {
+			allowList, defaultList = defaults[entryType], true
+		}
+		if len(allowList) == 0 && len(denied[entryType]) == 0 {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("<b>%s</b>", entryType.Name()))
+		if len(allowList) > 0 {
+			suffix := ""
+			if defaultList {
+				suffix = " (default)"
+			}
+			usernames := strings.Join(dsext.Map(allowList, html.EscapeString), ", ")
+			lines = append(lines, fmt.Sprintf("Only from: <code>%s</code>%s", usernames, suffix))
+		}
+		if len(denied[entryType]) > 0 {
+			usernames := strings.Join(dsext.Map(denied[entryType], html.EscapeString), ", ")
+			lines = append(lines, fmt.Sprintf("Never from: <code>%s</code>", usernames))
+		}
+	}
+
+	if len(lines) == 0 {
+		return "You have no filters set up. Use /filter add or /filter deny to add some."
+	}
+	return strings.Join(lines, "\n")
+}
+
+func rulesHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	chatId, _ := chatIdFromUpdate(update)
+	user, userFound := userFromChatId(chatId, nil)
+	if !userFound {
+		_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatId,
+			Text:   "No user found for your Chat ID. Have you registered using the /start command?",
+		})
+		logSendMessageError(err)
+		return
+	}
+
+	reply := func(text string) {
+		_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:    chatId,
+			ParseMode: models.ParseModeHTML,
+			Text:      text,
+		})
+		logSendMessageError(err)
+	}
+
+	// Patterns may contain spaces, so only the leading arguments are split
+	messageParts := strings.Fields(update.Message.Text)
+
+	// First message part is always the command
+	if len(messageParts) < 2 || strings.EqualFold(messageParts[1], "list") {
+		reply(userRuleList(user))
+		return
+	}
+
+	usage := fmt.Sprintf("Submissions are only sent if they match none of your exclude rules and, if you have any "+
+		"include rules, at least one of them. Patterns enclosed in slashes are regular expressions. Usage examples:"+
+		"\n\n/rules add include title ych"+
+		"\n/rules add include description /commissions? (are )?open/"+
+		"\n/rules add exclude tags vore"+
+		"\n/rules remove 3"+
+		"\n/rules list"+
+		"\n\nFields: %s", strings.Join(dsext.Map(rules.Fields(), rules.Field.String), ", "))
+
+	switch strings.ToLower(messageParts[1]) {
+	case "add":
+		if len(messageParts) < 5 {
+			reply(usage)
+			return
+		}
+		action, err := rules.ParseAction(messageParts[2])
+		if err != nil {
+			reply(err.Error())
+			return
+		}
+		field, err := rules.ParseField(messageParts[3])
+		if err != nil {
+			reply(err.Error())
+			return
+		}
+		pattern := strings.Join(messageParts[4:], " ")
+		rule, err := rules.Parse(action, field, pattern)
+		if err != nil {
+			reply(html.EscapeString(err.Error()))
+			return
+		}
+		_, err = user.AddRule(rule, nil)
+		if err != nil {
+			logging.Errorf("Error saving rule of user %d: %v", user.ID, err)
+			reply("Error saving your rule, please try again later.")
+			return
+		}
+	case "remove":
+		if len(messageParts) < 3 {
+			reply(usage)
+			return
+		}
+		id, err := strconv.ParseUint(messageParts[2], 10, 32)
+		if err != nil {
+			reply("Please provide the number of the rule as shown by /rules list.")
+			return
+		}
+		removed, err := user.RemoveRule(uint(id), nil)
+		if err != nil {
+			logging.Errorf("Error removing rule %d of user %d: %v", id, user.ID, err)
+			reply("Error removing your rule, please try again later.")
+			return
+		}
+		if !removed {
+			reply(fmt.Sprintf("You have no rule with the number %d.", id))
+			return
+		}
+	default:
+		reply(usage)
+		return
+	}
+
+	// Reload the rules
+	user.Rules = nil
+	reply(userRuleList(user))
+}
+
+func userRuleList(user *db.User) string {
+	userRules := user.ContentRules()
+	if len(userRules) == 0 {
+		return "You have no rules set up. Use /rules add to add one."
+	}
+	lines := dsext.Map(userRules, func(userRule db.UserRule) string {
+		rule := rules.Rule{Action: userRule.Action, Field: userRule.Field, Pattern: userRule.Pattern, Regex: userRule.Regex}
+		return fmt.Sprintf("<b>%d</b>: <code>%s</code>", userRule.ID, html.EscapeString(rule.String()))
+	})
+	return "Your rules:\n" + strings.Join(lines, "\n")
+}
+
+func tierHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	chatId, _ := chatIdFromUpdate(update)
+	user, userFound := userFromChatId(chatId, nil)
+	if !userFound {
+		_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatId,
+			Text:   "No user found for your Chat ID. Have you registered using the /start command?",
+		})
+		logSendMessageError(err)
+		return
+	}
+
+	reply := func(text string) {
+		_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:    chatId,
+			ParseMode: models.ParseModeHTML,
+			Text:      text,
+		})
+		logSendMessageError(err)
+	}
+
+	messageParts := strings.Fields(update.Message.Text)
+
+	// First message part is always the command
+	if len(messageParts) < 3 {
+		reply("Notifications about priority artists are marked and always make a sound, muted artists are " +
+			"delivered silently. Usage examples:" +
+			"\n\n/tier priority artist1 artist2" +
+			"\n/tier muted artist3" +
+			"\n/tier normal artist1" +
+			"\n\n" + artistTierList(user))
+		return
+	}
+
+	tier, err := db.ParseArtistTier(messageParts[1])
+	if err != nil {
+		reply(err.Error())
+		return
+	}
+
+	txErr := db.Db().Transaction(func(tx *gorm.DB) error {
+		for _, username := range messageParts[2:] {
+			err := user.SetArtistTier(username, tier, tx)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if txErr != nil {
+		logging.Errorf("Error updating artist tiers of user %d: %v", user.ID, txErr)
+		reply("Error saving your artist tiers, please try again later.")
+		return
+	}
+
+	// Reload the tiers
+	user.ArtistTiers = nil
+	reply(artistTierList(user))
+}
+
+func artistTierList(user *db.User) string {
+	usersPerTier := make(map[db.ArtistTier][]string)
+	for _, userTier := range user.ArtistTierList() {
+		usersPerTier[userTier.Tier] = append(usersPerTier[userTier.Tier], html.EscapeString(userTier.Username))
+	}
+	if len(usersPerTier) == 0 {
+		return "All artists are in the normal tier."
+	}
+	lines := make([]string, 0, len(usersPerTier))
+	for _, tier := range db.ArtistTiers() {
+		if len(usersPerTier[tier]) > 0 {
+			lines = append(lines, fmt.Sprintf("<b>%s</b>: <code>%s</code>", tier, strings.Join(usersPerTier[tier], ", ")))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
 func privacyPolicyHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
 	chatId, _ := chatIdFromUpdate(update)
 	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
@@ -249,3 +720,107 @@ func privacyPolicyHandler(ctx context.Context, b *bot.Bot, update *models.Update
 	})
 	logSendMessageError(err)
 }
+
+func exportHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	chatId, _ := chatIdFromUpdate(update)
+	user, userFound := userFromChatId(chatId, nil)
+	if !userFound {
+		_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatId,
+			Text:   "No data is stored about you.",
+		})
+		logSendMessageError(err)
+		return
+	}
+
+	export, err := db.ExportUserData(user.ID)
+	var data []byte
+	if err == nil {
+		data, err = json.MarshalIndent(export, "", "  ")
+	}
+	if err != nil {
+		logging.Errorf("Error exporting data of user %d: %v", user.ID, err)
+		_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatId,
+			Text:   "Exporting your data failed, please try again later.",
+		})
+		logSendMessageError(err)
+		return
+	}
+
+	_, err = b.SendDocument(ctx, &bot.SendDocumentParams{
+		ChatID: chatId,
+		Document: &models.InputFileUpload{
+			Filename: fmt.Sprintf("fa-notifier-export-%s.json", export.ExportedAt.Format("20060102")),
+			Data:     bytes.NewReader(data),
+		},
+		Caption: "All data stored about you. The values of your cookies have been redacted.",
+	})
+	logSendMessageError(err)
+}
+
+// deleteConfirmationText has to be sent by the user to confirm the deletion of their data
+const deleteConfirmationText = "DELETE"
+
+func deleteMeHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	chatId, _ := chatIdFromUpdate(update)
+	if _, userFound := userFromChatId(chatId, nil); !userFound {
+		_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatId,
+			Text:   "No data is stored about you.",
+		})
+		logSendMessageError(err)
+		return
+	}
+	convHandler.SetActiveConversationStage(chatId, stageDeleteConfirmation)
+
+	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:    chatId,
+		ParseMode: models.ParseModeHTML,
+		Text: conversationMessage(fmt.Sprintf(
+			"This permanently deletes all data stored about you, including your cookies and settings. "+
+				"You will not receive any notifications afterwards. Use /export first if you want to keep a copy.\n\n"+
+				"Send <code>%s</code> to confirm.", deleteConfirmationText,
+		)),
+	})
+	logSendMessageError(err)
+}
+
+// deleteUser deletes all data of a user. It is replaced by main to wait for a running update of the user first.
+var deleteUser = func(ctx context.Context, userId uint) error {
+	return db.DeleteUserData(userId)
+}
+
+func SetUserDeletion(deletion func(ctx context.Context, userId uint) error) {
+	deleteUser = deletion
+}
+
+func deleteConfirmationHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	chatId, _ := chatIdFromUpdate(update)
+	if strings.TrimSpace(update.Message.Text) != deleteConfirmationText {
+		_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:    chatId,
+			ParseMode: models.ParseModeHTML,
+			Text:      conversationMessage(fmt.Sprintf("Please send <code>%s</code> to confirm.", deleteConfirmationText)),
+		})
+		logSendMessageError(err)
+		return
+	}
+	convHandler.EndConversation(chatId)
+
+	text := "All data stored about you has been deleted. Use /start if you want to use this bot again."
+	user, userFound := userFromChatId(chatId, nil)
+	if userFound {
+		if err := deleteUser(ctx, user.ID); err != nil {
+			logging.Errorf("Error deleting data of user %d: %v", user.ID, err)
+			text = "Deleting your data failed, please try again later."
+		} else {
+			logging.Infof("Deleted all data of user %d", user.ID)
+		}
+	}
+	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: chatId,
+		Text:   text,
+	})
+	logSendMessageError(err)
+}
@@ -0,0 +1,281 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fanonwue/goutils/logging"
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/senexdrake/furaffinity-notifier/internal/db"
+)
+
+const historyButtonDataPrefix = "history:"
+const searchButtonDataPrefix = "search:"
+
+// historyPageSize is the number of notifications listed per page
+const historyPageSize = 10
+
+// maxCallbackDataLength is the maximum size of the data of an inline keyboard button allowed by Telegram
+const maxCallbackDataLength = 64
+
+// maxPageOffset is only used to check whether the data of the page buttons fits into Telegram's limit
+const maxPageOffset = 999999
+
+// historyPage identifies a page of the notification history. Its arguments are the ones the user has entered, so the
+// page buttons can repeat the query.
+type historyPage struct {
+	prefix string
+	offset int
+	args   []string
+}
+
+func (hp historyPage) buttonData(offset int) string {
+	return hp.prefix + strings.Join(append([]string{strconv.Itoa(offset)}, hp.args...), ":")
+}
+
+// fitsButtonData returns true if the page buttons of the query can be sent to Telegram.
+func (hp historyPage) fitsButtonData() bool {
+	return len(hp.buttonData(maxPageOffset)) <= maxCallbackDataLength
+}
+
+// query returns the notification query of the page and a description of it.
+func (hp historyPage) query() (db.NotificationQuery, string, error) {
+	if hp.prefix == searchButtonDataPrefix {
+		text := hp.args[0]
+		return db.NotificationQuery{Text: text}, fmt.Sprintf("Results for <code>%s</code>", html.EscapeString(text)), nil
+	}
+
+	typeName, author := hp.args[0], hp.args[1]
+	query := db.NotificationQuery{Author: author}
+	description := "Notifications"
+	if typeName != "" {
+		entryTypes, found := filterEntryTypes[typeName]
+		if !found {
+			return query, "", fmt.Errorf("unknown type <code>%s</code>", html.EscapeString(typeName))
+		}
+		query.EntryTypes = entryTypes
+		description = "Notifications about " + strings.ReplaceAll(typeName, "_", " ")
+	}
+	if author != "" {
+		description += fmt.Sprintf(" from <code>%s</code>", html.EscapeString(author))
+	}
+	return query, description, nil
+}
+
+// dataToHistoryPage parses the data of a page button.
+func dataToHistoryPage(data string) (historyPage, bool) {
+	page := historyPage{}
+	argCount := 0
+	switch {
+	case strings.HasPrefix(data, historyButtonDataPrefix):
+		page.prefix, argCount = historyButtonDataPrefix, 2
+	case strings.HasPrefix(data, searchButtonDataPrefix):
+		page.prefix, argCount = searchButtonDataPrefix, 1
+	default:
+		return page, false
+	}
+
+	// The last argument may contain the separator itself, like the text of a search
+	parts := strings.SplitN(strings.TrimPrefix(data, page.prefix), ":", argCount+1)
+	if len(parts) != argCount+1 {
+		return page, false
+	}
+	offset, err := strconv.Atoi(parts[0])
+	if err != nil || offset < 0 {
+		return page, false
+	}
+	page.offset, page.args = offset, parts[1:]
+	return page, true
+}
+
+func historyHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	chatId, _ := chatIdFromUpdate(update)
+	messageParts := strings.Fields(update.Message.Text)
+
+	// First message part is always the command
+	args := messageParts[1:]
+	typeName, author := "", ""
+	if len(args) > 0 {
+		if _, found := filterEntryTypes[strings.ToLower(args[0])]; found {
+			typeName, args = strings.ToLower(args[0]), args[1:]
+		}
+	}
+	if len(args) > 0 {
+		author, args = strings.TrimPrefix(args[0], "~"), args[1:]
+	}
+	if len(args) > 0 || strings.Contains(author, ":") {
+		_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:    chatId,
+			ParseMode: models.ParseModeHTML,
+			Text: "Usage examples:" +
+				"\n\n/history" +
+				"\n/history submissions" +
+				"\n/history journals artist1" +
+				"\n/history artist1" +
+				"\n\nTypes: " + strings.Join(slices.Sorted(maps.Keys(filterEntryTypes)), ", "),
+		})
+		logSendMessageError(err)
+		return
+	}
+
+	sendHistoryPage(ctx, b, chatId, historyPage{prefix: historyButtonDataPrefix, args: []string{typeName, author}})
+}
+
+func searchHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	chatId, _ := chatIdFromUpdate(update)
+	_, text, _ := strings.Cut(update.Message.Text, " ")
+	text = strings.TrimSpace(text)
+	if text == "" {
+		_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatId,
+			Text:   "Please enter a text to search for in the titles and authors of your notifications, e.g. /search dragon",
+		})
+		logSendMessageError(err)
+		return
+	}
+
+	sendHistoryPage(ctx, b, chatId, historyPage{prefix: searchButtonDataPrefix, args: []string{text}})
+}
+
+func sendHistoryPage(ctx context.Context, b *bot.Bot, chatId int64, page historyPage) {
+	reply := func(text string) {
+		_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:    chatId,
+			ParseMode: models.ParseModeHTML,
+			Text:      text,
+		})
+		logSendMessageError(err)
+	}
+
+	user, userFound := userFromChatId(chatId, nil)
+	if !userFound {
+		reply("No user found for your Chat ID. Have you registered using the /start command?")
+		return
+	}
+	if !page.fitsButtonData() {
+		reply("Your query is too long, please shorten it.")
+		return
+	}
+
+	text, keyboard, err := historyPageContent(user, page)
+	if err != nil {
+		reply(err.Error())
+		return
+	}
+	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:             chatId,
+		ParseMode:          models.ParseModeHTML,
+		Text:               text,
+		ReplyMarkup:        keyboard,
+		LinkPreviewOptions: defaultLinkPreviewOptions(),
+	})
+	logSendMessageError(err)
+}
+
+func onHistoryPageSelect(ctx context.Context, b *bot.Bot, update *models.Update) {
+	b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{CallbackQueryID: update.CallbackQuery.ID})
+
+	chatId, err := chatIdFromUpdate(update)
+	if err != nil {
+		return
+	}
+	page, valid := dataToHistoryPage(update.CallbackQuery.Data)
+	if !valid {
+		return
+	}
+	user, userFound := userFromChatId(chatId, nil)
+	if !userFound {
+		return
+	}
+
+	text, keyboard, err := historyPageContent(user, page)
+	if err != nil {
+		return
+	}
+	_, err = b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		MessageID:          update.CallbackQuery.Message.Message.ID,
+		ChatID:             chatId,
+		ParseMode:          models.ParseModeHTML,
+		Text:               text,
+		ReplyMarkup:        keyboard,
+		LinkPreviewOptions: defaultLinkPreviewOptions(),
+	})
+	if err != nil {
+		logging.Errorf("Error editing history message: %s", err)
+	}
+}
+
+// historyPageContent lists the notifications of the page along with the buttons to switch to the previous and next
+// page.
+func historyPageContent(user *db.User, page historyPage) (string, models.ReplyMarkup, error) {
+	query, description, err := page.query()
+	if err != nil {
+		return "", nil, err
+	}
+	records, total, err := db.FindNotifications(user.ID, query, page.offset, historyPageSize)
+	if err != nil {
+		logging.Errorf("Error reading notification history of user %d: %v", user.ID, err)
+		return "", nil, fmt.Errorf("error reading your notifications, please try again later")
+	}
+	if len(records) == 0 {
+		return description + ": none found.", nil, nil
+	}
+
+	location, err := user.GetLocation()
+	if err != nil {
+		location = time.UTC
+	}
+	lines := make([]string, 0, len(records)+1)
+	lines = append(lines, fmt.Sprintf("%s (%d-%d of %d):", description, page.offset+1, page.offset+len(records), total))
+	for i, record := range records {
+		lines = append(lines, "\n"+notificationRecordText(page.offset+i+1, &record, location))
+	}
+
+	buttons := make([]models.InlineKeyboardButton, 0, 2)
+	if page.offset > 0 {
+		buttons = append(buttons, models.InlineKeyboardButton{
+			Text:         "Previous",
+			CallbackData: page.buttonData(max(page.offset-historyPageSize, 0)),
+		})
+	}
+	if int64(page.offset+len(records)) < total {
+		buttons = append(buttons, models.InlineKeyboardButton{
+			Text:         "Next",
+			CallbackData: page.buttonData(page.offset + len(records)),
+		})
+	}
+	if len(buttons) == 0 {
+		// An untyped nil, as a nil pointer in the interface would still be sent as a keyboard
+		return strings.Join(lines, "\n"), nil, nil
+	}
+	keyboard := &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{buttons}}
+	return strings.Join(lines, "\n"), keyboard, nil
+}
+
+func notificationRecordText(number int, record *db.NotificationRecord, location *time.Location) string {
+	title := record.Title
+	if title == "" {
+		title = "(no title)"
+	}
+	title = html.EscapeString(title)
+	if record.Link != "" {
+		title = fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(record.Link), title)
+	}
+
+	text := fmt.Sprintf("<b>%d.</b> %s: %s", number, entryTypeToText(record.EntryType), title)
+	if record.Author != "" {
+		text += fmt.Sprintf(" by <code>~%s</code>", html.EscapeString(record.Author))
+	}
+	date := record.Date
+	if date.IsZero() {
+		date = record.NotifiedAt
+	}
+	return text + "\n" + date.In(location).Format("2006-01-02 15:04")
+}
@@ -3,6 +3,7 @@ package telegram
 import (
 	"context"
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -12,11 +13,19 @@ import (
 	"github.com/go-telegram/bot/models"
 	"github.com/senexdrake/furaffinity-notifier/internal/conf"
 	"github.com/senexdrake/furaffinity-notifier/internal/db"
+	"github.com/senexdrake/furaffinity-notifier/internal/fa"
 	"github.com/senexdrake/furaffinity-notifier/internal/fa/entries"
 	"github.com/senexdrake/furaffinity-notifier/internal/util"
+	"gorm.io/gorm"
 )
 
 const buttonDataPrefix = "settings-"
+const ratingsButtonData = buttonDataPrefix + "ratings"
+const ratingButtonDataPrefix = buttonDataPrefix + "rating-"
+const backButtonData = buttonDataPrefix + "back"
+
+// ratingEntryTypes are the entry types which can have a rating other than general
+var ratingEntryTypes = []entries.EntryType{entries.EntryTypeSubmission, entries.EntryTypeJournal}
 
 var settingsKeyboardLayout = [][]entries.EntryType{
 	{entries.EntryTypeNote},
@@ -72,10 +81,60 @@ func settingsKeyboard() *models.InlineKeyboardMarkup {
 		})
 	})
 
+	buttons = append(buttons,
+		[]models.InlineKeyboardButton{{Text: "Ratings", CallbackData: ratingsButtonData}},
+		[]models.InlineKeyboardButton{{Text: "Cancel", CallbackData: "cancel"}},
+	)
+
+	return &models.InlineKeyboardMarkup{
+		InlineKeyboard: buttons,
+	}
+}
+
+func ratingToData(entryType entries.EntryType, rating fa.Rating) string {
+	return fmt.Sprintf("%s%d-%d", ratingButtonDataPrefix, entryType, rating)
+}
+
+func dataToRating(data string) (entries.EntryType, fa.Rating, bool) {
+	withoutPrefix := strings.TrimPrefix(data, ratingButtonDataPrefix)
+	entryTypeRaw, ratingRaw, found := strings.Cut(withoutPrefix, "-")
+	if !found {
+		return entries.EntryTypeInvalid, fa.RatingGeneral, false
+	}
+	entryType, err := strconv.Atoi(entryTypeRaw)
+	if err != nil {
+		return entries.EntryTypeInvalid, fa.RatingGeneral, false
+	}
+	rating, err := strconv.Atoi(ratingRaw)
+	if err != nil || rating < int(fa.RatingGeneral) || rating > int(fa.RatingAdult) {
+		return entries.EntryTypeInvalid, fa.RatingGeneral, false
+	}
+	return entries.EntryType(entryType), fa.Rating(rating), true
+}
+
+func ratingsKeyboard(user *db.User) *models.InlineKeyboardMarkup {
+	statusMap := user.EntryTypeStatus()
+	buttons := make([][]models.InlineKeyboardButton, 0, len(ratingEntryTypes)*2+1)
+	for _, entryType := range ratingEntryTypes {
+		status, enabled := statusMap[entryType]
+		buttons = append(buttons, []models.InlineKeyboardButton{
+			{Text: entryTypeToText(entryType), CallbackData: ratingsButtonData},
+		})
+		buttons = append(buttons, dsext.Map(fa.Ratings(), func(rating fa.Rating) models.InlineKeyboardButton {
+			emoji := util.EmojiCross
+			if enabled && status.Ratings.Allows(uint8(rating)) {
+				emoji = util.EmojiGreenCheck
+			}
+			return models.InlineKeyboardButton{
+				Text:         fmt.Sprintf("%c %s", emoji, rating),
+				CallbackData: ratingToData(entryType, rating),
+			}
+		}))
+	}
 	buttons = append(buttons, []models.InlineKeyboardButton{
+		{Text: "Back", CallbackData: backButtonData},
 		{Text: "Cancel", CallbackData: "cancel"},
 	})
-
 	return &models.InlineKeyboardMarkup{
 		InlineKeyboard: buttons,
 	}
@@ -146,6 +205,27 @@ func onSettingsKeyboardSelect(ctx context.Context, b *bot.Bot, update *models.Up
 		return
 	}
 
+	switch {
+	case queryData == ratingsButtonData || queryData == backButtonData:
+		tx.Rollback()
+		text, keyboard := ratingsStatusText, ratingsKeyboard(user)
+		if queryData == backButtonData {
+			text, keyboard = entryTypeStatusList(user), settingsKeyboard()
+		}
+		b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{CallbackQueryID: update.CallbackQuery.ID})
+		b.EditMessageText(ctx, &bot.EditMessageTextParams{
+			MessageID:   message.ID,
+			ChatID:      chatId,
+			ParseMode:   models.ParseModeHTML,
+			Text:        text,
+			ReplyMarkup: keyboard,
+		})
+		return
+	case strings.HasPrefix(queryData, ratingButtonDataPrefix):
+		onRatingToggle(ctx, b, update, user, tx)
+		return
+	}
+
 	entryType := dataToEntryType(queryData)
 	if !entries.ValidEntryTypesSet().Contains(entryType) {
 		tx.Rollback()
@@ -178,9 +258,76 @@ func onSettingsKeyboardSelect(ctx context.Context, b *bot.Bot, update *models.Up
 
 }
 
+func onRatingToggle(ctx context.Context, b *bot.Bot, update *models.Update, user *db.User, tx *gorm.DB) {
+	message := update.CallbackQuery.Message.Message
+	answer := func(text string) {
+		b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+			CallbackQueryID: update.CallbackQuery.ID,
+			ShowAlert:       false,
+			Text:            text,
+		})
+	}
+
+	if user == nil {
+		tx.Rollback()
+		answer("No user found for your Chat ID. Have you registered using the /start command?")
+		return
+	}
+
+	entryType, rating, valid := dataToRating(update.CallbackQuery.Data)
+	if !valid || !slices.Contains(ratingEntryTypes, entryType) {
+		tx.Rollback()
+		answer("")
+		return
+	}
+
+	status, enabled := user.EntryTypeStatus()[entryType]
+	if !enabled {
+		tx.Rollback()
+		answer(fmt.Sprintf("Please enable %s first", entryType.Name()))
+		return
+	}
+
+	ratings := status.Ratings.Toggle(uint8(rating))
+	err := user.SetEntryTypeRatings(entryType, ratings, tx)
+	if err != nil {
+		tx.Rollback()
+		answer(fmt.Sprintf("Could not change ratings: %s", err))
+		return
+	}
+	tx.Commit()
+
+	// Refresh user, keeping the previous one if that fails, which only shows the ratings as before the change
+	if refreshed, found := userFromChatId(user.TelegramChatId, nil); found {
+		user = refreshed
+	}
+
+	answer(responseTextRatingToggle(entryType, rating, ratings.Allows(uint8(rating))))
+	b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		MessageID:   message.ID,
+		ChatID:      user.TelegramChatId,
+		ParseMode:   models.ParseModeHTML,
+		Text:        ratingsStatusText,
+		ReplyMarkup: ratingsKeyboard(user),
+	})
+}
+
+func responseTextRatingToggle(entryType entries.EntryType, rating fa.Rating, enabled bool) string {
+	enabledText := "enabled"
+	if !enabled {
+		enabledText = "disabled"
+	}
+	return fmt.Sprintf("Rating %s has been %s for %s", rating, enabledText, entryType.Name())
+}
+
 func entryTypeStatusList(user *db.User) string {
 	statusMap := user.EntryTypeStatus()
-	filters := conf.EntryUserFilters()
+	filters := user.UserFilterLists(db.FilterKindAllow)
+	for entryType, users := range conf.EntryUserFilters() {
+		if len(filters[entryType]) == 0 {
+			filters[entryType] = users
+		}
+	}
 	entryStatusFunc := func(entryType entries.EntryType) rune {
 		_, found := statusMap[entryType]
 		if found {
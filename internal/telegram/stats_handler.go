@@ -0,0 +1,139 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fanonwue/goutils/dsext"
+	"github.com/fanonwue/goutils/logging"
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/senexdrake/furaffinity-notifier/internal/chart"
+	"github.com/senexdrake/furaffinity-notifier/internal/db"
+	"github.com/senexdrake/furaffinity-notifier/internal/fa/entries"
+)
+
+// busiestHourCount is the number of hours listed as the busiest ones
+const busiestHourCount = 3
+
+// statsCoverageNote explains that the statistics are based on the notification history, which older notifications are
+// not part of
+const statsCoverageNote = "Statistics only cover notifications received since the notification history was introduced."
+
+func statsHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	chatId, _ := chatIdFromUpdate(update)
+	reply := func(text string) {
+		_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:    chatId,
+			ParseMode: models.ParseModeHTML,
+			Text:      text,
+		})
+		logSendMessageError(err)
+	}
+
+	user, userFound := userFromChatId(chatId, nil)
+	if !userFound {
+		reply("No user found for your Chat ID. Have you registered using the /start command?")
+		return
+	}
+	location, err := user.GetLocation()
+	if err != nil {
+		location = time.UTC
+	}
+
+	stats, err := db.UserStatistics(user.ID, location, time.Now())
+	if err != nil {
+		logging.Errorf("Error computing statistics of user %d: %v", user.ID, err)
+		reply("Error computing your statistics, please try again later.")
+		return
+	}
+	longest := stats.Periods[len(stats.Periods)-1]
+	if longest.Total == 0 {
+		reply(fmt.Sprintf("You have not received any notifications in the last %s. %s", longest.Name, statsCoverageNote))
+		return
+	}
+	reply(statsText(stats))
+
+	image, err := statsChart(stats)
+	if err != nil {
+		logging.Errorf("Error rendering statistics chart of user %d: %v", user.ID, err)
+		return
+	}
+	_, err = b.SendPhoto(ctx, &bot.SendPhotoParams{
+		ChatID: chatId,
+		Photo: &models.InputFileUpload{
+			Filename: "stats.png",
+			Data:     bytes.NewReader(image),
+		},
+	})
+	logSendMessageError(err)
+}
+
+func statsText(stats *db.UserStats) string {
+	lines := make([]string, 0)
+	for _, period := range stats.Periods {
+		lines = append(lines, fmt.Sprintf("<b>Last %s</b>: %d", period.Name, period.Total))
+		typeCounts := make([]string, 0, len(period.EntryTypes))
+		for _, entryType := range entries.ValidEntryTypes() {
+			if count := period.EntryTypes[entryType]; count > 0 {
+				typeCounts = append(typeCounts, fmt.Sprintf("%s: %d", entryType.Name(), count))
+			}
+		}
+		if len(typeCounts) > 0 {
+			lines = append(lines, strings.Join(typeCounts, ", "))
+		}
+		if len(period.TopArtists) > 0 {
+			lines = append(lines, "Top artists: "+authorCountList(period.TopArtists))
+		}
+		lines = append(lines, "")
+	}
+
+	if len(stats.TopCommenters) > 0 {
+		lines = append(lines, "<b>Top commenters</b>: "+authorCountList(stats.TopCommenters), "")
+	}
+
+	hours := make([]int, 0, len(stats.Hours))
+	for hour, count := range stats.Hours {
+		if count > 0 {
+			hours = append(hours, hour)
+		}
+	}
+	if len(hours) > 0 {
+		slices.SortStableFunc(hours, func(a, b int) int { return stats.Hours[b] - stats.Hours[a] })
+		busiest := dsext.Map(hours[:min(len(hours), busiestHourCount)], func(hour int) string {
+			return fmt.Sprintf("%02d:00 (%d)", hour, stats.Hours[hour])
+		})
+		lines = append(lines, fmt.Sprintf("<b>Busiest hours</b> (received, %s): %s", stats.Location, strings.Join(busiest, ", ")), "")
+	}
+	lines = append(lines, "<i>"+statsCoverageNote+"</i>")
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+func authorCountList(counts []db.AuthorCount) string {
+	return strings.Join(dsext.Map(counts, func(ac db.AuthorCount) string {
+		return fmt.Sprintf("<code>~%s</code> (%d)", html.EscapeString(ac.Author), ac.Count)
+	}), ", ")
+}
+
+// statsChart renders the notifications per day and the hours they have been received at.
+func statsChart(stats *db.UserStats) ([]byte, error) {
+	days := chart.BarChart{Title: "Notifications per day"}
+	for _, day := range stats.Days {
+		days.Labels = append(days.Labels, strconv.Itoa(day.Date.Day()))
+		days.Values = append(days.Values, day.Count)
+	}
+	hours := chart.BarChart{
+		Title:  fmt.Sprintf("Notifications by hour received (%s)", stats.Location),
+		Values: stats.Hours[:],
+	}
+	for hour := range stats.Hours {
+		hours.Labels = append(hours.Labels, strconv.Itoa(hour))
+	}
+	return chart.Render(days, hours)
+}
@@ -40,12 +40,21 @@ This bot saves the following user information:
 2. Your provided user information:
 	- Unread notes setting
 	- Your timezone
+	- Your update interval
 
 3. Your FurAffinity cookies 
 	- these are very sensitive, this allows the bot to fully impersonate you, which is required due to how FurAffinity works
 
 4. A list of IDs that belong to your FurAffinity account: Note IDs, Comment IDs, Submission IDs and Journal IDs
 	- this is needed to keep track of entries this bot has notified you about already. No content is stored, although it is fetched temporarily when notifying you.
+	- if FurAffinity changes the layout of a page, a copy of that page may be kept on the server to adapt the bot. It is not sent to anyone.
+
+5. Your notification settings: enabled entry types and ratings, blocked tags, filtered users, rules and artist tiers
+
+6. A history of the notifications sent to you: type, ID, title, author, date and link of each entry
+	- this allows you to find past notifications using /history and /search.
+
+Use /export to receive all data stored about you and /delete_me to delete it.
 `)
 
 var statusTemplate = util.TrimHtmlText(`
@@ -59,12 +68,18 @@ Current settings:
 <b>Journal Comments</b>: %s
 `)
 
+var ratingsStatusText = util.TrimHtmlText(`
+Click one of the ratings to toggle whether you want to be notified about entries with that rating.
+Notes and comments always have a general rating.
+`)
+
 var conversationMessageSuffix = "\n\nTo cancel, use the /cancel command."
 
 func templateFuncMap() template.FuncMap {
 	return template.FuncMap{
 		"formatContent": truncateMessage,
 		"toLower":       strings.ToLower,
+		"join":          strings.Join,
 		"formatUser": func(u *fa.FurAffinityUser) string {
 			prefixedUserName := "~" + u.UserName
 			if u.DisplayName == "" || u.DisplayName == u.UserName {
@@ -1,12 +1,17 @@
 package telegram
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
 	"net/url"
 
 	"github.com/fanonwue/goutils"
 	"github.com/fanonwue/goutils/logging"
 	"github.com/go-telegram/bot/models"
 	"github.com/senexdrake/furaffinity-notifier/internal/conf"
+	"github.com/senexdrake/furaffinity-notifier/internal/fa"
 	"github.com/senexdrake/furaffinity-notifier/internal/fa/tools"
 )
 
@@ -107,3 +112,31 @@ func linkPreviewWithThumbnailOrFullView(fullView *url.URL, thumbnail *tools.Thum
 func conversationMessage(msg string) string {
 	return msg + conversationMessageSuffix
 }
+
+// downloadFile downloads the file at the given URL into memory. It fails if the file is larger than maxSize bytes. The
+// download passes the request limiter of FA, as the files are hosted there.
+func downloadFile(ctx context.Context, fileUrl *url.URL, maxSize int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileUrl.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := fa.HttpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error downloading '%s': %s", fileUrl, resp.Status)
+	}
+	if resp.ContentLength > maxSize {
+		return nil, fmt.Errorf("file '%s' is too large (%d bytes)", fileUrl, resp.ContentLength)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("file '%s' is larger than %d bytes", fileUrl, maxSize)
+	}
+	return data, nil
+}
@@ -51,9 +51,15 @@ type (
 		Description  string
 		ThumbnailUrl string
 		FullViewUrl  string
+		DownloadUrl  string
 		Rating       fa.Rating
 		Type         fa.SubmissionType
 		Blocked      bool
+		BlockedTags  []string
+		// HideContent only shows the title of the submission, used for blocked submissions
+		HideContent bool
+		// Metadata holds the details from the view page, nil if the submission content could not be retrieved
+		Metadata *fa.SubmissionMetadata
 	}
 )
 
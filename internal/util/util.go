@@ -16,6 +16,7 @@ const (
 	EmojiSquareRed   = rune('🟥')
 	EmojiSquareBlue  = rune('🟦')
 	EmojiSquareWhite = rune('⬜')
+	EmojiStar        = rune('⭐')
 )
 
 const (
@@ -98,3 +99,17 @@ func BackwardsChannelWithCapacity[T any](channel <-chan T, cap uint) <-chan T {
 
 	return reversedChannel
 }
+
+// FormatBytes formats a size in bytes with a binary unit, e.g. "1.5 MiB".
+func FormatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
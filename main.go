@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"os"
 	"os/signal"
 	"slices"
@@ -12,6 +13,7 @@ import (
 
 	"github.com/fanonwue/goutils"
 	"github.com/fanonwue/goutils/buildinfo"
+	"github.com/fanonwue/goutils/dsext"
 	"github.com/fanonwue/goutils/logging"
 	"github.com/joho/godotenv"
 	"github.com/senexdrake/furaffinity-notifier/internal/conf"
@@ -19,10 +21,27 @@ import (
 	"github.com/senexdrake/furaffinity-notifier/internal/fa"
 	"github.com/senexdrake/furaffinity-notifier/internal/fa/entries"
 	"github.com/senexdrake/furaffinity-notifier/internal/misc"
+	"github.com/senexdrake/furaffinity-notifier/internal/schedule"
 	"github.com/senexdrake/furaffinity-notifier/internal/telegram"
 	"github.com/senexdrake/furaffinity-notifier/internal/util"
 )
 
+const (
+	// schedulerTick is the resolution at which the scheduler checks for users that are due for an update
+	schedulerTick    = 5 * time.Second
+	maxUpdateBackoff = time.Hour
+	updateJitter     = 0.1
+	// shutdownTimeout is the maximum time to wait for running updates to finish their deliveries on shutdown
+	shutdownTimeout = 45 * time.Second
+	// pruneInterval is the interval at which known entries outside the retention policy are removed
+	pruneInterval = 6 * time.Hour
+	// lockedUserDelay is how long the update of a user is postponed if another update of the user is still running
+	lockedUserDelay = 30 * time.Second
+)
+
+// userLocks prevents overlapping updates for the same user, even if a run takes longer than the scheduling interval
+var userLocks = schedule.NewUserLocks()
+
 func init() {
 	dotenvErr := godotenv.Load()
 	logLevelErr := logging.SetLogLevelFromEnvironment(util.PrefixEnvVar("LOG_LEVEL"))
@@ -32,7 +51,11 @@ func init() {
 	if logLevelErr != nil {
 		logging.Errorf("error setting log level: %v", logLevelErr)
 	}
+}
 
+// setup reads the configuration of the bot. It is not part of init, so commands like migrations can run without a
+// complete bot configuration.
+func setup() {
 	logging.Info("---- SETTING UP BOT ----")
 	logging.Info("Welcome to FurAffinity Notifier!")
 
@@ -44,6 +67,10 @@ func init() {
 	logging.Info("Build info: " + buildInfoString)
 
 	conf.Setup()
+	db.SetCookieKeyring(conf.CookieKeyring())
+	telegram.SetBackupOptions(backupOptions())
+	fa.SetRequestLimits(conf.FaRequestsPerSecond(), conf.FaMaxInFlight())
+	logging.Infof("Limiting requests to FA to %.2f per second with at most %d in flight", conf.FaRequestsPerSecond(), conf.FaMaxInFlight())
 
 	if conf.EnableKitoraRequestFormCheck() {
 		logging.Infof("Kitora request form check enabled. User %d will be notified about future availability.", misc.KitoraNotificationTarget())
@@ -51,6 +78,15 @@ func init() {
 }
 
 func main() {
+	if handled, err := runCommand(os.Args[1:]); handled {
+		if err != nil {
+			logging.Errorf("%v", err)
+			os.Exit(1)
+		}
+		return
+	}
+	setup()
+
 	appContext, cancel := signal.NotifyContext(context.Background(),
 		os.Interrupt,
 		os.Kill,
@@ -60,12 +96,28 @@ func main() {
 	defer cancel()
 	db.CreateDatabase()
 
+	interval := updateInterval()
+	scheduler := schedule.New(schedulerConfig(interval))
+	telegram.SetUserDeletion(func(ctx context.Context, userId uint) error {
+		return deleteUser(ctx, scheduler, userId)
+	})
+
 	logging.Infof("Starting Bot...")
 	_ = telegram.StartBot(appContext)
 
-	go StartBackgroundUpdates(appContext, updateInterval())
+	updatesDone := make(chan struct{})
+	go func() {
+		defer close(updatesDone)
+		StartBackgroundUpdates(appContext, scheduler, interval)
+	}()
 
 	<-appContext.Done()
+	logging.Info("Shutting down, waiting for running updates to finish")
+	select {
+	case <-updatesDone:
+	case <-time.After(shutdownTimeout):
+		logging.Warnf("Running updates did not finish within %.0f seconds", shutdownTimeout.Seconds())
+	}
 	logging.Info("Bot exiting!")
 }
 
@@ -82,147 +134,486 @@ func updateInterval() time.Duration {
 	return interval
 }
 
-func StartBackgroundUpdates(ctx context.Context, interval time.Duration) {
-	logging.Infof("Starting background updates at an interval of %.0f seconds", interval.Seconds())
+func StartBackgroundUpdates(ctx context.Context, scheduler *schedule.Scheduler, interval time.Duration) {
+	logging.Infof("Starting background updates at a base interval of %.0f seconds", interval.Seconds())
 	defer logging.Info("BackgroundUpdates stopped")
-	UpdateJob()
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
+
+	pool := schedule.NewPool(conf.UpdateWorkers())
+	logging.Infof("Updating at most %d users in parallel", pool.Size())
+	defer pool.Wait()
+	wg := sync.WaitGroup{}
+	defer wg.Wait()
+
+	if conf.EnableMiscJobs {
+		runMisc(ctx, &wg)
+	}
+	retention := retentionPolicy()
+	if retention.Enabled() {
+		wg.Go(func() { PruneJob(ctx, retention) })
+	}
+	UpdateJob(ctx, scheduler, pool)
+
+	updateTicker := time.NewTicker(schedulerTick)
+	defer updateTicker.Stop()
+	intervalTicker := time.NewTicker(interval)
+	defer intervalTicker.Stop()
+	pruneTicker := time.NewTicker(pruneInterval)
+	defer pruneTicker.Stop()
+	backupTicker := newOptionalTicker(conf.BackupInterval())
+	defer backupTicker.Stop()
 	for {
 		select {
-		case <-ticker.C:
-			UpdateJob()
+		case <-updateTicker.C:
+			UpdateJob(ctx, scheduler, pool)
+		case <-pruneTicker.C:
+			if retention.Enabled() {
+				wg.Go(func() { PruneJob(ctx, retention) })
+			}
+		case <-backupTicker.C:
+			wg.Go(func() { BackupJob(ctx) })
+		case <-intervalTicker.C:
+			logRequestStats()
+			logUpdateStats(scheduler)
+			if conf.EnableMiscJobs {
+				runMisc(ctx, &wg)
+			}
 		case <-ctx.Done():
-			// The context is over, stop processing results
+			// The context is over, stop scheduling new updates. Running updates abort their pending requests and
+			// finish the deliveries already in progress before the deferred Wait returns.
 			return
 		}
 	}
 }
 
-func UpdateJob() {
-	users := make([]db.User, 0)
-	db.Db().
-		Preload("EntryTypes").
-		Preload("Cookies").
-		Find(&users)
+func schedulerConfig(interval time.Duration) schedule.Config {
+	minInterval := max(envSeconds("UPDATE_INTERVAL_MIN", interval/2), conf.MinimumUpdateInterval)
+	maxInterval := envSeconds("UPDATE_INTERVAL_MAX", interval*5)
+	logging.Infof("Adaptive update intervals range from %.0f to %.0f seconds", minInterval.Seconds(), maxInterval.Seconds())
+	return schedule.Config{
+		BaseInterval: interval,
+		MinInterval:  minInterval,
+		MaxInterval:  maxInterval,
+		MaxBackoff:   maxUpdateBackoff,
+		Jitter:       updateJitter,
+	}
+}
 
-	wg := sync.WaitGroup{}
+func envSeconds(key string, defaultValue time.Duration) time.Duration {
+	raw, err := strconv.Atoi(os.Getenv(util.PrefixEnvVar(key)))
+	if err != nil {
+		return defaultValue
+	}
+	return time.Duration(raw) * time.Second
+}
 
-	if conf.EnableMiscJobs {
-		runMisc(&wg)
+func userCandidate(user *db.User) schedule.Candidate {
+	return schedule.Candidate{ID: user.ID, Override: user.UpdateIntervalOverride()}
+}
+
+// UpdateJob starts an update for the users that are due according to the scheduler, as long as there are idle
+// workers in the pool. The updates run in the background, users that did not fit into the pool are picked up by one of
+// the next calls, most overdue first.
+func UpdateJob(ctx context.Context, scheduler *schedule.Scheduler, pool *schedule.Pool) {
+	if ctx.Err() != nil {
+		return
+	}
+
+	candidates := make([]db.User, 0)
+	db.Db().Select("id", "update_interval_seconds").Find(&candidates)
+
+	due := scheduler.Due(time.Now(), dsext.Map(candidates, func(u db.User) schedule.Candidate {
+		return userCandidate(&u)
+	}), pool.Free())
+	if len(due) == 0 {
+		return
+	}
+
+	users := make([]db.User, 0, len(due))
+	err := db.Db().
+		Preload("EntryTypes").
+		Preload("Cookies").
+		Preload("BlockedTags").
+		Preload("Filters").
+		Preload("Rules").
+		Preload("ArtistTiers").
+		Find(&users, due).Error
+	if err != nil {
+		logging.Errorf("Error loading users for update: %v", err)
+		users = nil
+	}
+	// Due marks all users as running, so those that are not updated have to be released, or they are never due again.
+	// Users might have been deleted since they were selected as candidates.
+	for _, id := range due {
+		if !slices.ContainsFunc(users, func(u db.User) bool { return u.ID == id }) {
+			scheduler.Release(id)
+		}
 	}
 
-	// Do checks synchronously for now to prevent any massive rate limiting
 	for _, user := range users {
-		wg.Go(func() {
-			updateForUser(&user)
+		started := pool.TryGo(func() {
+			if !userLocks.TryLock(user.ID) {
+				logging.Warnf("Update for user %d is still running, postponing", user.ID)
+				scheduler.Postpone(user.ID, time.Now().Add(lockedUserDelay))
+				return
+			}
+			defer userLocks.Unlock(user.ID)
+			if !db.UserExists(user.ID) {
+				// Deleted while the update was waiting for the lock
+				scheduler.Remove(user.ID)
+				return
+			}
+
+			start := time.Now()
+			outcome := updateForUser(ctx, &user)
+			outcome.Duration = time.Since(start)
+			logging.Debugf("Finished update for user %d in %s", user.ID, outcome.Duration.Round(time.Millisecond))
+			if ctx.Err() != nil {
+				// Results of cancelled runs are meaningless, don't let them affect the schedule
+				return
+			}
+			if unavailable, ok := fa.AsUnavailable(outcome.Err); ok {
+				// FA itself is down, this is not the user's fault, so pause everyone instead of backing off this user
+				handleOutage(ctx, scheduler, unavailable)
+				outcome.Err = nil
+			} else if outcome.Err == nil && scheduler.EndOutage() {
+				logging.Info("FA is available again, resuming updates")
+				telegram.HandleOutageResolved(ctx)
+			}
+			nextRun := scheduler.Complete(time.Now(), userCandidate(&user), outcome)
+			logging.Debugf("Next update for user %d scheduled at %s", user.ID, nextRun.Format(time.DateTime))
 		})
+		if !started {
+			// Only this function submits to the pool, so this should not happen. Try again on the next tick.
+			scheduler.Release(user.ID)
+		}
 	}
+}
 
-	wg.Wait()
+func handleOutage(ctx context.Context, scheduler *schedule.Scheduler, unavailable *fa.UnavailableError) {
+	pausedUntil, started := scheduler.ReportOutage(time.Now())
+	logging.Warnf("FA is unavailable (%s), pausing all updates until %s", unavailable.Kind, pausedUntil.Format(time.DateTime))
+	if started {
+		telegram.HandleOutageStarted(ctx, unavailable, pausedUntil)
+	}
 }
 
-func applyUserFilters(c *fa.FurAffinityCollector) {
+// applyUserFilters sets the filters of the user on the collector. The filters configured via environment variables are
+// used for all entry types the user has no allow-list for.
+func applyUserFilters(c *fa.FurAffinityCollector, user *db.User) {
+	allowed := user.UserFilterLists(db.FilterKindAllow)
 	for entryType, users := range conf.EntryUserFilters() {
-		if users != nil && len(users) > 0 {
+		if len(allowed[entryType]) == 0 {
 			c.SetUserFilter(entryType, users)
 		}
 	}
+	for entryType, users := range allowed {
+		c.SetUserFilter(entryType, users)
+	}
+	for entryType, users := range user.UserFilterLists(db.FilterKindDeny) {
+		c.SetUserDenyFilter(entryType, users)
+	}
 }
 
-func updateForUser(user *db.User) {
+func updateForUser(ctx context.Context, user *db.User) schedule.Outcome {
+	outcome := schedule.Outcome{}
 	if user == nil {
 		logging.Errorf("user is nil, skipping update")
-		return
+		return outcome
 	}
 	logging.Debugf("Running update for user %d", user.ID)
+	if user.HasUnreadableCookies() {
+		logging.Warnf("Cookies of user %d could not be decrypted, skipping", user.ID)
+		if !user.InvalidCredentialsNotified() {
+			telegram.HandleInvalidCredentials(ctx, user, true)
+		}
+		return outcome
+	}
 	c := fa.NewCollector(user)
-	c.LimitConcurrency = 4
+	c.LimitConcurrency = conf.UserConcurrency()
 	c.IterateSubmissionsBackwards = conf.IterateSubmissionsBackwards()
 	c.RespectBlockedTags = conf.EnableBlockedTags
-
-	if conf.EnableLoginCheck() {
-		// Check whether the user has valid credentials
-		loggedIn, err := c.IsLoggedIn()
+	c.ExtraBlockedTags = user.ExtraBlockedTags()
+	c.Rules = user.RuleSet()
+	c.DetectMarkupDrift = conf.EnableDriftDetection()
+	c.DriftDumpDir = conf.DriftDumpPath()
+	c.OnDrift = telegram.HandleMarkupDrift
+
+	var counters *fa.MessageCounters
+	if conf.EnableLoginCheck() || conf.EnableCounterProbe() {
+		// A single probe request checks the login status and reads the message counters at the same time
+		probe, err := c.Probe(ctx)
 		if err != nil {
-			logging.Errorf("Error checking login status for user %d: %s", c.UserID(), err)
-			return
+			logging.Errorf("Error probing FA for user %d: %s", c.UserID(), err)
+			outcome.Err = err
+			return outcome
 		}
-		if !loggedIn {
-			logging.Warnf("User %d does not have valid credentials, skipping", c.UserID())
-			// Send notification if the user has not been notified yet
-			if user.InvalidCredentialsSentAt == nil {
-				telegram.HandleInvalidCredentials(user, true)
+
+		if conf.EnableLoginCheck() {
+			if !probe.LoggedIn {
+				logging.Warnf("User %d does not have valid credentials, skipping", c.UserID())
+				// Send notification if the user has not been notified yet
+				if user.InvalidCredentialsSentAt == nil {
+					telegram.HandleInvalidCredentials(ctx, user, true)
+				}
+				return outcome
 			}
-			return
+
+			// User logged in, reset any invalid credentials notification data
+			user.ResetCredentialsValid(nil)
 		}
 
-		// User logged in, reset any invalid credentials notification data
-		user.ResetCredentialsValid(nil)
+		if conf.EnableCounterProbe() {
+			counters = probe.Counters
+		}
 	}
 
 	// set filters
 	if conf.EnableUserFilters {
-		applyUserFilters(c)
+		applyUserFilters(c, user)
 	}
 
 	entryTypes := user.EnabledEntryTypes()
+	shouldScrape := func(entryType entries.EntryType) bool {
+		if !slices.Contains(entryTypes, entryType) {
+			return false
+		}
+		if entryType == entries.EntryTypeNote && !user.UnreadNotesOnly {
+			// The note counter only contains unread notes, but the user wants to be notified about read notes as well
+			return true
+		}
+		hasNew := counters.HasNew(entryType)
+		if !hasNew {
+			logging.Debugf("Page header shows no new '%s' entries for user %d, skipping", entryType.Name(), user.ID)
+		}
+		return hasNew
+	}
 
-	if conf.EnableNotes && slices.Contains(entryTypes, entries.EntryTypeNote) {
-		channel := c.GetNewNotesWithContent()
-		entryHandlerWrapper(user, channel, func(note *fa.NoteEntry) {
-			telegram.HandleNewNote(note, user)
-		})
+	// Stop early once FA turns out to be unavailable, the remaining pages would fail as well
+	available := func() bool {
+		_, unavailable := fa.AsUnavailable(outcome.Err)
+		return !unavailable && c.Unavailable() == nil
 	}
 
-	if conf.EnableSubmissions && slices.Contains(entryTypes, entries.EntryTypeSubmission) {
-		channel := submissionsChannel(c)
-		entryHandlerWrapper(user, channel, func(submission *fa.SubmissionEntry) {
-			telegram.HandleNewSubmission(submission, user)
+	if conf.EnableNotes && shouldScrape(entries.EntryTypeNote) {
+		channel, report := c.GetNewNotesWithContent(ctx)
+		outcome.NewEntries += entryHandlerWrapper(ctx, user, channel, func(note *fa.NoteEntry) {
+			telegram.HandleNewNote(ctx, note, user)
 		})
+		recordRun(user, "notes", report, &outcome)
 	}
 
-	if conf.EnableOtherEntries {
-		channel := c.GetNewOtherEntriesWithContent(entryTypes...)
-		entryHandlerWrapper(user, channel, func(entry fa.Entry) {
-			telegram.HandleNewEntry(entry, user)
+	if conf.EnableSubmissions && available() && shouldScrape(entries.EntryTypeSubmission) {
+		channel, report := submissionsChannel(ctx, c)
+		outcome.NewEntries += entryHandlerWrapper(ctx, user, channel, func(submission *fa.SubmissionEntry) {
+			telegram.HandleNewSubmission(ctx, submission, user)
 		})
+		recordRun(user, "submissions", report, &outcome)
+	}
+
+	if conf.EnableOtherEntries && available() {
+		otherEntryTypes := dsext.Filter(fa.OtherEntryTypes(), shouldScrape)
+		if len(otherEntryTypes) > 0 {
+			channel, report := c.GetNewOtherEntriesWithContent(ctx, otherEntryTypes...)
+			outcome.NewEntries += entryHandlerWrapper(ctx, user, channel, func(entry fa.Entry) {
+				telegram.HandleNewEntry(ctx, entry, user)
+			})
+			recordRun(user, "other messages", report, &outcome)
+		}
+	}
+	if unavailable := c.Unavailable(); unavailable != nil && outcome.Err == nil {
+		// Maintenance pages may be served with a regular status code, so they do not always show up as failed requests
+		outcome.Err = unavailable
 	}
-	logging.Debugf("Finished update for user %d", user.ID)
+	return outcome
 }
 
-func entryHandlerWrapper[T fa.BaseEntry](user *db.User, entryChannel <-chan T, entryHandler func(entry T)) {
+// recordRun logs the summary of a single scrape run and adds its errors to the outcome, so the scheduler can tell a
+// run without new entries from a failed one.
+func recordRun(user *db.User, name string, report *fa.RunReport, outcome *schedule.Outcome) {
+	summary := report.Summary()
+	logging.Debugf(
+		"Scraped %s for user %d: %d pages fetched, %d HTTP failures, %d parse failures, %d content failures",
+		name,
+		user.ID,
+		summary.PagesFetched,
+		summary.HttpFailures,
+		summary.ParseFailures,
+		summary.ContentFailures,
+	)
+	if summary.ParseFailures > 0 || summary.ContentFailures > 0 {
+		logging.Warnf("Scraping %s for user %d was incomplete: %d entries could not be parsed, %d contents could not be retrieved",
+			name, user.ID, summary.ParseFailures, summary.ContentFailures)
+	}
+
+	err := report.Err()
+	if err == nil {
+		return
+	}
+	logging.Errorf("Scraping %s failed for user %d: %s", name, user.ID, err)
+	outcome.Err = errors.Join(outcome.Err, err)
+}
+
+// entryHandlerWrapper passes all entries of the channel to the handler. Once the context is done, remaining entries are
+// drained without being handled, so the scrapers can shut down; they will be picked up again by the next run.
+func entryHandlerWrapper[T fa.BaseEntry](ctx context.Context, user *db.User, entryChannel <-chan T, entryHandler func(entry T)) int {
 	if user == nil {
 		logging.Errorf("user is nil, skipping update")
-		return
+		return 0
 	}
 	defer goutils.PanicHandler(func(err any) {
 		logging.Errorf("Recovered from panic while running update for user %d: %s", user.ID, err)
 	})
 
+	handled := 0
 	for entry := range entryChannel {
+		if ctx.Err() != nil {
+			continue
+		}
 		logging.Infof("Notifying user %d about '%s' %d", user.ID, entry.EntryType().Name(), entry.ID())
 		entryHandler(entry)
+		handled++
 	}
-
+	return handled
 }
 
-func submissionsChannel(c *fa.FurAffinityCollector) <-chan *fa.SubmissionEntry {
+func submissionsChannel(ctx context.Context, c *fa.FurAffinityCollector) (<-chan *fa.SubmissionEntry, *fa.RunReport) {
 	if conf.EnableSubmissionsContent {
-		return c.GetNewSubmissionEntriesWithContent()
+		return c.GetNewSubmissionEntriesWithContent(ctx)
+	}
+	return c.GetNewSubmissionEntries(ctx)
+}
+
+func logRequestStats() {
+	stats := fa.RequestLimiterStats()
+	if stats.Requests == 0 {
+		return
+	}
+	logging.Infof(
+		"FA requests: %d total, %d in flight, %d waiting, average queue wait %s, maximum queue wait %s",
+		stats.Requests,
+		stats.InFlight,
+		stats.Waiting,
+		stats.AverageWait().Round(time.Millisecond),
+		stats.MaxWait.Round(time.Millisecond),
+	)
+}
+
+func logUpdateStats(scheduler *schedule.Scheduler) {
+	stats := scheduler.Stats()
+	if len(stats) == 0 {
+		return
+	}
+
+	maxDelay := time.Duration(0)
+	for _, userStats := range stats {
+		maxDelay = max(maxDelay, userStats.LastDelay)
+	}
+	logging.Infof("Updates: %d users, longest start delay of the last runs %s", len(stats), maxDelay.Round(time.Second))
+
+	for _, id := range schedule.Slowest(stats, 3) {
+		userStats := stats[id]
+		logging.Infof(
+			"Updates for user %d: %d runs, %d failed, last %s, average %s, maximum %s",
+			id,
+			userStats.Runs,
+			userStats.Failures,
+			userStats.LastDuration.Round(time.Millisecond),
+			userStats.AverageDuration().Round(time.Millisecond),
+			userStats.MaxDuration.Round(time.Millisecond),
+		)
+	}
+}
+
+func retentionPolicy() db.RetentionPolicy {
+	policy := db.RetentionPolicy{MaxAge: conf.KnownEntryRetention(), KeepLatest: conf.KnownEntryKeepLatest()}
+	if policy.Enabled() {
+		logging.Infof("Pruning known entries older than %.0f days, keeping the latest %d per type",
+			policy.MaxAge.Hours()/24, policy.KeepLatest)
+	}
+	return policy
+}
+
+// PruneJob removes the known entries of all users that fall outside the retention policy. Users with a running update
+// are skipped until the next run, as the update might still rely on the entries that are about to be pruned.
+func PruneJob(ctx context.Context, policy db.RetentionPolicy) {
+	userIds := make([]uint, 0)
+	db.Db().Model(&db.User{}).Pluck("id", &userIds)
+
+	now := time.Now()
+	pruned := int64(0)
+	for _, userId := range userIds {
+		if ctx.Err() != nil {
+			return
+		}
+		if !userLocks.TryLock(userId) {
+			logging.Debugf("Update for user %d is running, not pruning known entries", userId)
+			continue
+		}
+		count, err := db.PruneKnownEntries(userId, policy, now)
+		userLocks.Unlock(userId)
+		if err != nil {
+			logging.Errorf("Error pruning known entries of user %d: %v", userId, err)
+		}
+		pruned += count
+	}
+	if pruned > 0 {
+		logging.Infof("Pruned %d known entries", pruned)
+	}
+}
+
+// deleteUser deletes all data of the user. A running update of the user is waited for, so it can't recreate any of
+// the deleted data afterwards.
+func deleteUser(ctx context.Context, scheduler *schedule.Scheduler, userId uint) error {
+	if err := userLocks.Lock(ctx, userId); err != nil {
+		return err
+	}
+	defer userLocks.Unlock(userId)
+	if err := db.DeleteUserData(userId); err != nil {
+		return err
+	}
+	scheduler.Remove(userId)
+	return nil
+}
+
+func backupOptions() db.BackupOptions {
+	return db.BackupOptions{
+		Dir:           conf.BackupDir(),
+		Keep:          conf.BackupKeep(),
+		Compress:      conf.BackupCompress(),
+		RedactCookies: conf.BackupRedactCookies(),
+	}
+}
+
+// BackupJob creates a scheduled backup of the database and notifies the bot creator if it fails.
+func BackupJob(ctx context.Context) {
+	info, err := db.CreateBackup(backupOptions(), time.Now())
+	if err != nil {
+		logging.Errorf("Error creating scheduled backup: %v", err)
+		telegram.HandleBackupFailed(ctx, err)
+		return
+	}
+	logging.Infof("Created backup %s (%s)", info.Path, util.FormatBytes(info.Size))
+}
+
+// newOptionalTicker returns a ticker for the given interval, which never ticks if the interval is 0.
+func newOptionalTicker(interval time.Duration) *time.Ticker {
+	if interval <= 0 {
+		ticker := time.NewTicker(time.Hour)
+		ticker.Stop()
+		return ticker
 	}
-	return c.GetNewSubmissionEntries()
+	return time.NewTicker(interval)
 }
 
-func runMisc(wg *sync.WaitGroup) {
+func runMisc(ctx context.Context, wg *sync.WaitGroup) {
 	if conf.EnableKitoraRequestFormCheck() {
 		wg.Go(func() {
-			checkKitoraCommissionStatus(misc.KitoraNotificationTarget())
+			checkKitoraCommissionStatus(ctx, misc.KitoraNotificationTarget())
 		})
 	}
 }
 
-func checkKitoraCommissionStatus(notificationTarget int64) {
+func checkKitoraCommissionStatus(ctx context.Context, notificationTarget int64) {
 	if misc.KitoraHasNotified() {
 		return
 	}
@@ -240,7 +631,7 @@ func checkKitoraCommissionStatus(notificationTarget int64) {
 
 	logging.Infof("Notifiying user %d that the Kitora request form is open", notificationTarget)
 
-	_, err = telegram.SendMessage(notificationTarget, misc.KitoraMessageContent())
+	_, err = telegram.SendMessage(ctx, notificationTarget, misc.KitoraMessageContent())
 	if err != nil {
 		logging.Errorf("error sending Kitora request form notification: %v", err)
 		return